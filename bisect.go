@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const BISECT_USAGE = "athina bisect [filename|--tree] [--good revision] [--bad revision] -- [command] [args]"
+
+// Commands that can not tell whether a version is good exit with this status, and the version is skipped
+const BISECT_SKIP_STATUS int = 125
+
+var errBisectInconclusive = errors.New("bisect is inconclusive, the versions in between were skipped")
+
+// The outcome of testing a single version
+type bisectVerdict int
+
+const (
+	BISECT_GOOD bisectVerdict = iota
+	BISECT_BAD
+	BISECT_SKIP
+)
+
+func (v bisectVerdict) String() string {
+
+	switch v {
+	case BISECT_GOOD:
+		return colorize(COLOR_GREEN, "good")
+	case BISECT_BAD:
+		return colorize(COLOR_RED, "bad")
+	}
+
+	return colorize(COLOR_YELLOW, "skipped")
+}
+
+// Binary-searches the versions between a good one at index good and a bad one at index bad for the first bad version.
+// Skipped versions are worked around, when only skipped versions are left the candidates for the first bad version are returned with an error
+func bisect(good int, bad int, test func(index int) (bisectVerdict, error)) (int, []int, error) {
+
+	skipped := map[int]bool{}
+	for bad-good > 1 {
+
+		// Test the untested version closest to the middle
+		mid := -1
+		center := good + (bad-good)/2
+		for distance := 0; distance < bad-good && mid < 0; distance++ {
+			for _, i := range []int{center - distance, center + distance} {
+				if i > good && i < bad && !skipped[i] {
+					mid = i
+					break
+				}
+			}
+		}
+
+		if mid < 0 {
+			var candidates []int
+			for i := good + 1; i <= bad; i++ {
+				candidates = append(candidates, i)
+			}
+			return bad, candidates, errBisectInconclusive
+		}
+
+		verdict, err := test(mid)
+		if err != nil {
+			return -1, nil, err
+		}
+
+		switch verdict {
+		case BISECT_GOOD:
+			good = mid
+		case BISECT_BAD:
+			bad = mid
+		case BISECT_SKIP:
+			skipped[mid] = true
+		}
+	}
+
+	return bad, nil, nil
+}
+
+// Runs the command against the tree and turns its exit status into a verdict
+func testTree(tree Tree, command []string) (bisectVerdict, error) {
+
+	code, err := runInTree(tree, command)
+	if err != nil {
+		return BISECT_SKIP, err
+	}
+
+	switch code {
+	case 0:
+		return BISECT_GOOD, nil
+	case BISECT_SKIP_STATUS:
+		return BISECT_SKIP, nil
+	}
+
+	return BISECT_BAD, nil
+}
+
+// Finds the first bad version of a single file. Every other file is kept at its latest recorded version
+func bisectFile(filename string, goodRevision string, badRevision string, command []string) error {
+
+	athinafile, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return err
+	}
+
+	good, err := resolveRevision(athinafile, goodRevision)
+	if err != nil {
+		return err
+	}
+
+	bad, err := resolveRevision(athinafile, badRevision)
+	if err != nil {
+		return err
+	}
+
+	if good >= bad {
+		return &UsageError{Usage: BISECT_USAGE + " (the good revision has to be older than the bad revision)"}
+	}
+
+	latest, err := currentTree()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bisecting %d changes of \"%s\"\n", bad-good, filename)
+
+	first, candidates, err := bisect(good, bad, func(index int) (bisectVerdict, error) {
+		filediff := athinafile.Diffs[index]
+
+		tree := Tree{Files: map[string]string{}}
+		for name, hash := range latest.Files {
+			tree.Files[name] = hash
+		}
+		delete(tree.Files, filename)
+		if !filediff.Deleted || filediff.Added {
+			tree.Files[filename] = filediff.Hash
+		}
+
+		verdict, err := testTree(tree, command)
+		if err == nil {
+			fmt.Printf("%s: %s\n", colorize(COLOR_YELLOW, shortenHash(filediff.Hash)), verdict)
+		}
+		return verdict, err
+	})
+
+	if errors.Is(err, errBisectInconclusive) {
+		var hashes []string
+		for _, i := range candidates {
+			hashes = append(hashes, athinafile.Diffs[i].Hash)
+		}
+		return &FileError{Filename: filename, Err: fmt.Errorf("%w, the first bad change is one of %s", err, strings.Join(hashes, ", "))}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("The first bad change of \"" + filename + "\" is:")
+	return printFileHistory(filename, 1, versionSpec{revision: athinafile.Diffs[first].Hash})
+}
+
+// Finds the first bad snapshot of the current branch, between the good and the bad one, and shows the changes it brought to every file
+func bisectTree(goodSnapshot string, badSnapshot string, command []string) error {
+
+	trees, err := loadTrees()
+	if err != nil {
+		return err
+	}
+
+	indexOf := func(snapshot string) (int, error) {
+		tree, err := resolveSnapshot(snapshot)
+		if err != nil {
+			return -1, err
+		}
+
+		for i := range trees {
+			if trees[i].Hash == tree.Hash {
+				return i, nil
+			}
+		}
+
+		return -1, &FileError{Filename: snapshot, Err: fmt.Errorf("%w, it is not a recorded snapshot of this branch", errSnapshotNotFound)}
+	}
+
+	// Without a good snapshot, the search starts at the oldest one
+	good := 0
+	if goodSnapshot != "" {
+		good, err = indexOf(goodSnapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	bad, err := indexOf(badSnapshot)
+	if err != nil {
+		return err
+	}
+
+	if good >= bad {
+		return &UsageError{Usage: BISECT_USAGE + " (the good snapshot has to be older than the bad snapshot)"}
+	}
+
+	fmt.Printf("Bisecting %d snapshots\n", bad-good)
+
+	first, candidates, err := bisect(good, bad, func(index int) (bisectVerdict, error) {
+		verdict, err := testTree(trees[index], command)
+		if err == nil {
+			fmt.Printf("%s: %s\n", colorize(COLOR_YELLOW, shortenHash(trees[index].Hash)), verdict)
+		}
+		return verdict, err
+	})
+
+	if errors.Is(err, errBisectInconclusive) {
+		var hashes []string
+		for _, i := range candidates {
+			hashes = append(hashes, trees[i].Hash)
+		}
+		return fmt.Errorf("%w, the first bad snapshot is one of %s", err, strings.Join(hashes, ", "))
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The first bad snapshot is %s (%s)\n", colorize(COLOR_YELLOW, trees[first].Hash), trees[first].Time.Local().Format("2006-01-02 15:04:05"))
+	return printTreeChanges(trees[first-1], trees[first])
+}
+
+// Prints which files were added or deleted between two trees, and the Filediffs of every file that changed
+func printTreeChanges(before Tree, after Tree) error {
+
+	var filenames []string
+	for filename := range before.Files {
+		filenames = append(filenames, filename)
+	}
+	for filename := range after.Files {
+		if _, ok := before.Files[filename]; !ok {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		oldHash, existed := before.Files[filename]
+		newHash, exists := after.Files[filename]
+
+		switch {
+		case !existed:
+			fmt.Println("Added file: " + filename)
+		case !exists:
+			fmt.Println("Deleted file: " + filename)
+		case oldHash != newHash:
+			athinafile, err := loadAthinaFileObject(filename)
+			if err != nil {
+				return err
+			}
+
+			oldIndex, err := resolveRevision(athinafile, oldHash)
+			if err != nil {
+				return err
+			}
+
+			newIndex, err := resolveRevision(athinafile, newHash)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("Changed file: " + filename)
+			err = printFileHistory(filename, newIndex-oldIndex, versionSpec{revision: newHash})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func handleBisectCommand(args []string) error {
+
+	args, command, err := splitCommand(args, BISECT_USAGE)
+	if err != nil {
+		return err
+	}
+
+	tree, args := extractFlag(args, "--tree")
+
+	good, _, args, err := extractFlagValue(args, "--good")
+	if err != nil {
+		return err
+	}
+
+	bad, _, args, err := extractFlagValue(args, "--bad")
+	if err != nil {
+		return err
+	}
+
+	if tree {
+		if len(args) != 0 {
+			return &UsageError{Usage: BISECT_USAGE}
+		}
+
+		if bad == "" {
+			bad = REVISION_LATEST
+		}
+
+		return bisectTree(good, bad, command)
+	}
+
+	if len(args) != 1 {
+		return &UsageError{Usage: BISECT_USAGE}
+	}
+
+	filename, err := resolveRepositoryPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	if good == "" {
+		good = REVISION_ORIGIN
+	}
+	if bad == "" {
+		bad = REVISION_LATEST
+	}
+
+	return bisectFile(filename, good, bad, command)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBisect(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		good       int
+		bad        int
+		firstBad   int
+		skipped    []int
+		first      int
+		candidates []int
+		err        error
+	}{
+		{"first bad in the middle", 0, 8, 5, nil, 5, nil, nil},
+		{"first bad right after good", 0, 8, 1, nil, 1, nil, nil},
+		{"only bad is bad", 0, 8, 8, nil, 8, nil, nil},
+		{"range of length 1", 3, 4, 4, nil, 4, nil, nil},
+		{"range of length 2", 3, 5, 4, nil, 4, nil, nil},
+		{"skip next to good", 0, 3, 3, []int{1}, 3, nil, nil},
+		{"skips next to bad", 0, 4, 1, []int{2, 3}, 1, nil, nil},
+		{"skips at both edges", 0, 6, 3, []int{1, 5}, 3, nil, nil},
+		{"first bad skipped", 0, 5, 4, []int{4}, 5, []int{4, 5}, errBisectInconclusive},
+		{"first bad skipped next to good", 0, 5, 1, []int{1}, 2, []int{1, 2}, errBisectInconclusive},
+		{"all skipped", 0, 5, 3, []int{1, 2, 3, 4}, 5, []int{1, 2, 3, 4, 5}, errBisectInconclusive},
+		{"all of a range of length 2 skipped", 3, 5, 4, []int{4}, 5, []int{4, 5}, errBisectInconclusive},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tested := map[int]bool{}
+			first, candidates, err := bisect(test.good, test.bad, func(index int) (bisectVerdict, error) {
+				if index <= test.good || index >= test.bad {
+					t.Errorf("tested version %d outside of (%d, %d)", index, test.good, test.bad)
+				}
+				if tested[index] {
+					t.Errorf("tested version %d twice", index)
+				}
+				tested[index] = true
+
+				switch {
+				case slices.Contains(test.skipped, index):
+					return BISECT_SKIP, nil
+				case index >= test.firstBad:
+					return BISECT_BAD, nil
+				}
+				return BISECT_GOOD, nil
+			})
+
+			if !errors.Is(err, test.err) {
+				t.Fatalf("bisect() error = %v, want %v", err, test.err)
+			}
+			if first != test.first || !slices.Equal(candidates, test.candidates) {
+				t.Errorf("bisect() = %d, %v, want %d, %v", first, candidates, test.first, test.candidates)
+			}
+		})
+	}
+}
+
+func TestBisectTestFailure(t *testing.T) {
+
+	failure := errors.New("could not run the command")
+	first, _, err := bisect(0, 8, func(index int) (bisectVerdict, error) {
+		return BISECT_SKIP, failure
+	})
+
+	if !errors.Is(err, failure) || first != -1 {
+		t.Errorf("bisect() = %d, %v, want -1, %v", first, err, failure)
+	}
+}
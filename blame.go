@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A line of a file, together with the Filediff that last touched it
+type blameLine struct {
+	text     string
+	filediff Filediff
+}
+
+// Replays the Filediffs of the AthinaFile up to and including the one at index until,
+// and tracks for every line which Filediff introduced it
+func blameAthinaFile(athinafile AthinaFile, until int) ([]blameLine, error) {
+
+	var lines []blameLine
+	err := replayAthinaFile(athinafile, func(index int, filediff Filediff, before string, after string) error {
+
+		if index > until || (index > 0 && before == after) {
+			return nil
+		}
+
+		// Lines that are kept carry their origin along, inserted lines are attributed to this Filediff
+		var nextLines []blameLine
+		i := 0
+		for _, diff := range diffLines(before, after) {
+			switch diff.Type {
+			case diffmatchpatch.DiffEqual:
+				nextLines = append(nextLines, lines[i:i+len(diff.Lines)]...)
+				i += len(diff.Lines)
+			case diffmatchpatch.DiffDelete:
+				i += len(diff.Lines)
+			case diffmatchpatch.DiffInsert:
+				for _, line := range diff.Lines {
+					nextLines = append(nextLines, blameLine{text: line, filediff: filediff})
+				}
+			}
+		}
+
+		lines = nextLines
+		return nil
+	})
+
+	return lines, err
+}
+
+func printFileBlame(filename string, spec versionSpec) error {
+
+	athinafile, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return err
+	}
+
+	// Blame the latest recorded version, unless we are asked for an older one
+	until, err := resolveVersion(athinafile, spec)
+	if err != nil {
+		return err
+	}
+
+	lines, err := blameAthinaFile(athinafile, until)
+	if err != nil {
+		return err
+	}
+
+	for number, line := range lines {
+		fmt.Printf("%s %-16s %-8s %4d) %s\n",
+			colorize(COLOR_YELLOW, shortenHash(line.filediff.Hash)),
+			line.filediff.formatTime(),
+			line.filediff.Change.short(),
+			number+1,
+			strings.TrimRight(line.text, "\r\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Branches are parallel lines of history. Every branch has a complete object store of its own:
+// the default branch keeps using .athina/objects, every other branch lives in .athina/branches/<name>.
+// A new branch starts out as a copy of the branch it was created from, so the histories of two branches share their older hashes.
+// .athina/HEAD names the branch that status, update and every other command work on
+const DEFAULT_BRANCH = "main"
+const ATHINA_HEAD = ".athina/HEAD"
+const ATHINA_BRANCHES = ".athina/branches/"
+
+const BRANCH_USAGE = "athina branch [list] | athina branch create [name] [--from branch] | athina branch delete [name]"
+const SWITCH_USAGE = "athina switch [branch] [--force]"
+
+var currentBranch = DEFAULT_BRANCH
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Returns the folder that holds the object store of the branch
+func branchPath(name string) string {
+
+	if name == DEFAULT_BRANCH {
+		return ATHINA_FOLDER + "/"
+	}
+
+	return ATHINA_BRANCHES + name + "/"
+}
+
+func setCurrentBranch(name string) {
+	currentBranch = name
+	packIndex = nil
+}
+
+// Runs fn with another branch temporarily checked out, without touching HEAD or the working files
+func onBranch(name string, fn func() error) error {
+
+	previous := currentBranch
+	setCurrentBranch(name)
+	defer setCurrentBranch(previous)
+
+	return fn()
+}
+
+// Reads which branch is checked out from .athina/HEAD. Repositories from before branches existed have no HEAD, and are on the default branch
+func loadCurrentBranch() error {
+
+	content, err := os.ReadFile(ATHINA_HEAD)
+	if os.IsNotExist(err) {
+		setCurrentBranch(DEFAULT_BRANCH)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(string(content))
+	if !branchExists(name) {
+		return &CorruptObjectError{Filename: ATHINA_HEAD, Err: fmt.Errorf("%w: %q", ErrBranchNotFound, name)}
+	}
+
+	setCurrentBranch(name)
+	return nil
+}
+
+func validateBranchName(name string) error {
+
+	if !validName.MatchString(name) {
+		return &UsageError{Usage: BRANCH_USAGE + " (branch names may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit)"}
+	}
+
+	return nil
+}
+
+func branchExists(name string) bool {
+
+	if name == DEFAULT_BRANCH {
+		return true
+	}
+
+	if validateBranchName(name) != nil {
+		return false
+	}
+
+	info, err := os.Stat(branchPath(name))
+	return err == nil && info.IsDir()
+}
+
+// Lists every branch, the default branch first
+func listBranches() ([]string, error) {
+
+	branches := []string{DEFAULT_BRANCH}
+
+	entries, err := os.ReadDir(ATHINA_BRANCHES)
+	if os.IsNotExist(err) {
+		return branches, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DEFAULT_BRANCH {
+			branches = append(branches, entry.Name())
+		}
+	}
+	slices.Sort(branches[1:])
+
+	return branches, nil
+}
+
+// Creates a branch as a copy of the objects and the pack of another branch
+func createBranch(name string, from string) error {
+
+	err := validateBranchName(name)
+	if err != nil {
+		return err
+	}
+
+	if branchExists(name) {
+		return &FileError{Filename: name, Err: fmt.Errorf("%w, the branch already exists", fs.ErrExist)}
+	}
+
+	if !branchExists(from) {
+		return &FileError{Filename: from, Err: ErrBranchNotFound}
+	}
+
+	for _, folder := range []string{"objects/", ATHINA_PACK_FOLDER} {
+		err = copyDirectory(branchPath(from)+folder, branchPath(name)+folder)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Debug("created branch", "branch", name, "from", from)
+
+	return nil
+}
+
+// Moves a branch into the trash. Neither the current nor the default branch can be deleted
+func deleteBranch(name string) error {
+
+	if !branchExists(name) {
+		return &FileError{Filename: name, Err: ErrBranchNotFound}
+	}
+
+	if name == currentBranch {
+		return &FileError{Filename: name, Err: errors.New("can not delete the current branch, switch to another one first")}
+	}
+
+	if name == DEFAULT_BRANCH {
+		return &FileError{Filename: name, Err: errors.New("can not delete the default branch")}
+	}
+
+	_, err := moveToTrash("delete branch "+name, []string{strings.TrimSuffix(branchPath(name), "/")})
+	return err
+}
+
+// Copies every file below source to the same place below target. A missing source is treated as empty
+func copyDirectory(source string, target string) error {
+
+	err := os.MkdirAll(target, 0755)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return os.MkdirAll(filepath.Join(target, relative), 0755)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(target, relative), content, 0644)
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// Returns the latest recorded content of every file that exists on the current branch
+func branchContents() (map[string]string, error) {
+
+	filenames, err := AthinaListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	contents := map[string]string{}
+	for _, filename := range filenames {
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		if athinafile.isDeleted() {
+			continue
+		}
+
+		contents[filename], err = emulateDeltaDiffsFromAthinaFileObject(athinafile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contents, nil
+}
+
+// Switches to another branch, and rewrites the working files to that branch's latest versions.
+// Working files with unrecorded changes, and untracked files that would be overwritten, are a conflict unless force is set
+func switchBranch(name string, force bool) error {
+
+	if !branchExists(name) {
+		return &FileError{Filename: name, Err: ErrBranchNotFound}
+	}
+
+	if name == currentBranch {
+		return nil
+	}
+
+	current, err := branchContents()
+	if err != nil {
+		return err
+	}
+
+	var target map[string]string
+	err = onBranch(name, func() error {
+		target, err = branchContents()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Every working file has to be exactly what the current branch recorded, or not be there at all when the current branch does not know it
+	if !force {
+		var filenames []string
+		for filename := range current {
+			filenames = append(filenames, filename)
+		}
+		for filename := range target {
+			if _, ok := current[filename]; !ok {
+				filenames = append(filenames, filename)
+			}
+		}
+		slices.Sort(filenames)
+
+		for _, filename := range filenames {
+			working, err := readOptionalFile(filename)
+			if err != nil {
+				return err
+			}
+
+			recorded, tracked := current[filename]
+			if working != nil && (!tracked || *working != recorded) {
+				if content, ok := target[filename]; ok && content == *working {
+					continue
+				}
+				return &FileError{Filename: filename, Err: fmt.Errorf("%w, record or discard it before switching, or use --force", ErrDirtyWorkingFile)}
+			}
+		}
+	}
+
+	for filename := range current {
+		if _, ok := target[filename]; !ok {
+			slog.Debug("removing working file that is not on the branch", "file", filename, "branch", name)
+			err := writeOptionalFile(filename, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for filename, content := range target {
+		err := writeOptionalFile(filename, &content)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.WriteFile(ATHINA_HEAD, []byte(name+"\n"), 0644)
+	if err != nil {
+		return err
+	}
+
+	// The switch is logged without any files, so that undo knows not to go back past it (see undoOperations)
+	previous := currentBranch
+	setCurrentBranch(name)
+	_, err = appendOperation(Operation{Command: []string{"switch", name}, Branch: name, Switched: previous})
+	return err
+}
+
+func handleBranchCommand(args []string) error {
+
+	subcommand := "list"
+	if len(args) > 0 {
+		subcommand, args = args[0], args[1:]
+	}
+
+	switch subcommand {
+	case "list":
+		branches, err := listBranches()
+		if err != nil {
+			return err
+		}
+
+		for _, branch := range branches {
+			if branch == currentBranch {
+				fmt.Println("* " + colorize(COLOR_GREEN, branch))
+			} else {
+				fmt.Println("  " + branch)
+			}
+		}
+
+	case "create":
+		from, found, args, err := extractFlagValue(args, "--from")
+		if err != nil {
+			return err
+		}
+		if !found {
+			from = currentBranch
+		}
+
+		if len(args) != 1 {
+			return &UsageError{Usage: BRANCH_USAGE}
+		}
+
+		err = createBranch(args[0], from)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Branch \"" + args[0] + "\" has been created from \"" + from + "\"")
+
+	case "delete":
+		if len(args) != 1 {
+			return &UsageError{Usage: BRANCH_USAGE}
+		}
+
+		err := deleteBranch(args[0])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Branch \"" + args[0] + "\" has been moved to the trash")
+
+	default:
+		return &UsageError{Usage: BRANCH_USAGE}
+	}
+
+	return nil
+}
+
+func handleSwitchCommand(args []string) error {
+
+	force, args := extractFlag(args, "--force", "-f")
+	if len(args) != 1 {
+		return &UsageError{Usage: SWITCH_USAGE}
+	}
+
+	err := switchBranch(args[0], force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Switched to branch \"" + currentBranch + "\"")
+
+	return nil
+}
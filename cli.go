@@ -3,37 +3,69 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-func resetAthinaRepository() {
+// Moves everything in the .athina folder, except for the trash and the operation log with its blobs, into the trash and starts over with an empty repository
+func resetAthinaRepository() error {
 
-	err := os.RemoveAll(ATHINA_FOLDER)
+	entries, err := os.ReadDir(ATHINA_FOLDER)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		path := filepath.Join(ATHINA_FOLDER, entry.Name())
+		if path != filepath.Clean(ATHINA_TRASH) && path != filepath.Clean(ATHINA_OPLOG) && path != filepath.Clean(ATHINA_BLOBS) {
+			paths = append(paths, path)
+		}
+	}
+
+	_, err = moveToTrash("reset repository", paths)
+	if err != nil {
+		return err
 	}
 
-	initializeAthinaFolder()
+	// Every branch went into the trash as well, only the default branch is started over
+	setCurrentBranch(DEFAULT_BRANCH)
+
+	err = initializeAthinaFolder()
+	if err != nil {
+		return err
+	}
+
+	// The repository config has been moved into the trash along with everything else
+	return loadConfig(true)
 
 }
 
-func addFileToIgnoreList(filename string) {
+func addFileToIgnoreList(filename string) error {
 
 	// Check if the filename is already ignored
-	found := false
-	for _, ignored := range config.Ignored {
-		if ignored == filename {
-			found = true
-			return
-		}
+	if config.IsIgnored(filename) {
+		return nil
 	}
 
-	if !found {
-		config.Ignored = append(config.Ignored, filename)
-		config.Save()
+	layer, err := getConfigLayer(CONFIG_SOURCE_REPOSITORY)
+	if err != nil {
+		return err
 	}
 
+	ignored, _ := layer.values["ignored"].([]any)
+	layer.values["ignored"] = append(ignored, filename)
+
+	err = layer.Save()
+	if err != nil {
+		return err
+	}
+
+	return mergeConfigLayers()
+
 }
 
 func initializeAthinaFolder() error {
@@ -42,7 +74,6 @@ func initializeAthinaFolder() error {
 	if _, err := os.Stat(ATHINA_FOLDER); os.IsNotExist(err) {
 		err := os.Mkdir(ATHINA_FOLDER, 0755)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 	}
@@ -51,7 +82,14 @@ func initializeAthinaFolder() error {
 	if _, err := os.Stat(ATHINA_PATH_TO_OBJECTS); os.IsNotExist(err) {
 		err := os.Mkdir(ATHINA_PATH_TO_OBJECTS, 0755)
 		if err != nil {
-			fmt.Println(err)
+			return err
+		}
+	}
+
+	// Ensure that there exists a .athina/hooks folder, where the user can put their hook executables
+	if _, err := os.Stat(ATHINA_HOOKS); os.IsNotExist(err) {
+		err := os.Mkdir(ATHINA_HOOKS, 0755)
+		if err != nil {
 			return err
 		}
 	}
@@ -60,14 +98,12 @@ func initializeAthinaFolder() error {
 	if _, err := os.Stat(ATHINA_CONFIG); os.IsNotExist(err) {
 		file, err := os.Create(ATHINA_CONFIG)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 		defer file.Close()
 
 		_, err = file.WriteString(`{"ignored":[]}`)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 
@@ -77,14 +113,12 @@ func initializeAthinaFolder() error {
 	if _, err := os.Stat(ATHINA_STASH); os.IsNotExist(err) {
 		file, err := os.Create(ATHINA_STASH)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 		defer file.Close()
 
 		_, err = file.WriteString(`{"stashes":[]}`)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 	}
@@ -92,21 +126,51 @@ func initializeAthinaFolder() error {
 	return nil
 }
 
-const DEFAULT_HISTORY_DEPTH int = 5
+const HISTORY_USAGE = "athina history [filename] [depth] [--from revision | --at time]"
 
-func printFileHistory(filename string, depth int) error {
+// Prints the history of the file, newest first, starting at the selected version
+func printFileHistory(filename string, depth int, from versionSpec) error {
 
 	// Load the Athina object
 	athinafile, err := loadAthinaFileObject(filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
-	for i := len(athinafile.Diffs) - 1; i >= 0 && depth > 0; i-- {
+	start, err := resolveVersion(athinafile, from)
+	if err != nil {
+		return err
+	}
+
+	tags, err := tagsByHash(filename)
+	if err != nil {
+		return err
+	}
+
+	for i := start; i >= 0 && depth > 0; i-- {
 		diff := athinafile.Diffs[i]
-		fmt.Println("Hash: " + diff.Hash)
+		fmt.Println("Hash: " + colorize(COLOR_YELLOW, diff.Hash))
+		if names, ok := tags[diff.Hash]; ok {
+			fmt.Println("Tags: " + colorize(COLOR_CYAN, strings.Join(names, ", ")))
+		}
 		fmt.Println("Change: " + string(diff.Change))
+		if diff.Author != "" {
+			fmt.Println("Author: " + diff.Author)
+		}
+		if !diff.Time.IsZero() {
+			fmt.Println("Date: " + diff.formatTime())
+		}
+		if diff.From != "" && diff.Change == AthinaFileChangeActionCopy {
+			fmt.Println("Copied from: " + diff.From)
+		} else if diff.From != "" {
+			fmt.Println("Renamed from: " + diff.From)
+		}
+		if len(diff.Parents) > 0 {
+			fmt.Println("Parents: " + strings.Join(diff.Parents, " "))
+		}
+		if diff.Message != "" {
+			fmt.Println("Message: " + diff.Message)
+		}
 		fmt.Println("Diff (Delta): " + diff.Delta)
 		depth--
 
@@ -115,133 +179,417 @@ func printFileHistory(filename string, depth int) error {
 	return nil
 }
 
-func AthinaListFiles() []string {
+// Lists the filenames of every tracked file, relative to the repository root, whether their objects are loose or packed
+func AthinaListFiles() ([]string, error) {
 
-	files, err := os.ReadDir(ATHINA_PATH_TO_OBJECTS)
+	filenames, err := listLooseAthinaObjects()
 	if err != nil {
-		fmt.Println(err)
-		return nil
+		return nil, err
 	}
 
-	var filenames []string
-	for _, file := range files {
-		filenames = append(filenames, file.Name())
+	index, err := loadPackIndex()
+	if err != nil {
+		return nil, err
 	}
 
-	return filenames
+	for filename := range index {
+		if !slices.Contains(filenames, filename) {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	return filenames, nil
 }
 
-func handleCLI(args []string) {
+func handleCLI(args []string) error {
 
 	// @NOTE: Args has already had the first element removed, meaning that args[0] is the first argument
 	if len(args) == 0 {
-		fmt.Println("Usage: athina [command] [args]")
 		fmt.Println("Type 'athina help' for more information")
-		return
+		return &UsageError{Usage: "athina [command] [args]"}
 	}
 
 	switch args[0] {
 
 	case "update": //@NOTE : This is basically a combination of the add and commit commands
 		if len(args) >= 2 {
-			for _, file := range args[1:] {
-				err := AthinaUpdateFile(file)
+			files, err := resolveRepositoryPaths(args[1:])
+			if err != nil {
+				return err
+			}
+
+			// Only files with changes are updated, and the hooks only run when there is any
+			var changed []string
+			for _, file := range files {
+				change, err := AthinaDetectFileChange(file)
+				if err != nil {
+					return err
+				}
+
+				if change.action == AthinaFileChangeActionNone {
+					fmt.Println("File \"" + file + "\" has no changes")
+					continue
+				}
+				changed = append(changed, file)
+			}
+
+			if len(changed) > 0 {
+				err = runHook(HOOK_PRE_UPDATE, hookFilesFromObjects(changed))
 				if err != nil {
-					fmt.Println(err)
-					return
+					return err
+				}
+
+				for _, file := range changed {
+					err := AthinaUpdateFile(file)
+					if err != nil {
+						return err
+					}
+					fmt.Println("File \"" + file + "\" has been updated")
 				}
-				fmt.Println("File \"" + file + "\" has been updated")
+
+				runPostHook(HOOK_POST_UPDATE, hookFilesFromObjects(changed))
 			}
 
 		} else {
 			// Update all files
-			err := AthinaUpdateAllFiles(true) //@NOTE : 'true' enables logging to stdout
+			err := AthinaUpdateAllFiles(!options.quiet) //@NOTE : 'true' enables logging to stdout
 			if err != nil {
-				fmt.Println(err)
-				return
+				return err
 			}
 			fmt.Println("All files have been updated")
 		}
 
+		if config.Prune.OnUpdate {
+			_, err := pruneAthinaFiles(nil, false, !options.quiet)
+			if err != nil {
+				return err
+			}
+		}
+
 	case "remove":
-		err := AthinaRemoveFile(args[1])
+		if len(args) < 2 {
+			return &UsageError{Usage: "athina remove [filename(s)]"}
+		}
+
+		files, err := resolveRepositoryPaths(args[1:])
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
+		}
+
+		for _, file := range files {
+			err := AthinaRemoveFile(file)
+			if err != nil {
+				return err
+			}
 		}
 
 	case "help":
-		fmt.Println("Usage: athina [command] [args]")
+		fmt.Println("Usage: " + GLOBAL_OPTIONS_USAGE)
+		fmt.Println("Global options:")
+		fmt.Println("  -q, --quiet : Only log errors, and keep the output of commands to a minimum")
+		fmt.Println("  -v, --verbose : Log debugging information")
+		fmt.Println("  --log-format [text|json] : Format of the log messages, defaults to text")
+		fmt.Println("  --log-file [path] : Append log messages to the given file instead of stderr")
+		fmt.Println("  --repo [path] : Use the repository at the given path instead of searching the current directory and its parents. Can also be set through $" + ATHINA_DIR_ENV)
 		fmt.Println("Commands:")
-		fmt.Println("  init:   Initialize Athina in the current directory")
+		fmt.Println("  init:   Initialize Athina in the current directory (or the one given with --repo). This is the only command that creates a repository")
 		fmt.Println("  update  [filename(s)] : Update the file(s) in the current directory. If no filename is provided, all files are updated")
-		fmt.Println("  remove  [filename(s)] : Remove the file(s) Athina metadata")
+		fmt.Println("  remove  [filename(s)] : Remove the file(s) Athina metadata, by moving it into the trash")
 		fmt.Println("  ignore  [filename(s)] : Add the file(s) to the ignore list")
-		fmt.Println("  reset   [filename(s)] : Reset the file(s), removing all history and making the current version the base. If no filename is provided, the entire repository is reset")
-		fmt.Println("  revert  [filename] [hash] : Revert the file to a previous version")
-		fmt.Println("  history [filename] [depth] : Print the history of the file. If no depth is provided, the default depth is 5")
+		fmt.Println("  mv      [old filename] [new filename] : Move the file and carry its history over to the new name. Files renamed by hand are detected on update when their content is at least rename.threshold similar (0 disables detection)")
+		fmt.Println("  cp      [source filename] [new filename] : Copy the file, the copy starts out with the full history of the source. New files that are at least copy.threshold similar to a tracked file are detected as copies on update (0, the default, disables detection)")
+		fmt.Println("  reset   [filename(s)] : Reset the file(s), removing all history and making the current version the base. If no filename is provided, the entire repository is reset after asking for confirmation (skipped with --yes). The old history is moved into the trash")
+		fmt.Println("  trash   [list|restore|empty] [id(s)] : List, restore or permanently delete what remove and reset moved into the trash. Emptying the whole trash asks for confirmation first (skipped with --yes)")
+		fmt.Println("  revert  [filename] [revision] | --at [time] : Revert the file to a previous version, selected by its revision or by the last change at or before a time such as \"2026-10-01 14:00\" or \"2 hours ago\"")
+		fmt.Println("  show    [filename] [revision] | --at [time] : Print the recorded content of the file at a version, the latest if none is given")
+		fmt.Println("  diff    [filename] [revision] | --at [time] : Show the difference between a recorded version of the file, the latest if none is given, and the working file")
+		fmt.Println("  restore [filename] [revision] : Recreate a deleted file from its last recorded content, or from its content at the given revision")
+		fmt.Println("  history [filename] [depth] [--from revision | --at time] : Print the history of the file, starting at the given version. If no depth is provided, the default depth is 5")
+		fmt.Println("  blame   [filename] [revision] | --at [time] : Show which recorded change last touched every line of the file, as of the latest version or the given one")
+		fmt.Println("  grep    [-S] [pattern] [filename(s)] : Search every recorded version of the file(s) for the pattern, with -S list the changes that added or removed occurrences of the string")
+		fmt.Println("  squash  [filename] [from revision] [to revision] [-m message] [--force] : Replace the changes from one revision up to and including another with a single change. Ranges containing a revert are refused without --force")
+		fmt.Println("  prune   [--dry-run] [filename(s)] : Drop the versions that the retention.rules config does not keep, e.g. \"*.log last=10\" or \"notes/* days=7 daily=30 weekly=52\". Runs after every update when prune.onupdate is set")
+		fmt.Println("  gc      [--pack] [--dry-run] : Drop the history of files deleted more than gc.deleteddays days ago (into the trash), empty changes, orphaned stash items and operations recorded more than gc.oplogdays days ago, and report the space reclaimed. With --pack every object is packed into a single indexed file")
+		fmt.Println("  branch  [list|create|delete] [name] [--from branch] : List, create or delete branches, parallel lines of history. A new branch starts as a copy of the current branch, or of the one given with --from")
+		fmt.Println("  switch  [branch] [--force] : Switch to the branch and rewrite the working files to its latest versions. Unrecorded changes are a conflict unless --force is given")
+		fmt.Println("  merge   [branch] [filename(s)] : Merge the history of the branch into the current branch, line by line from their common ancestor. Overlapping changes are written into the working file between conflict markers")
+		fmt.Println("  resolve [filename(s)] [--force] : Record the merge of files whose conflicts have been fixed by hand. Files that still have conflict markers are refused without --force")
+		fmt.Println("  pick    [filename] [revision] [--onto filename] : Apply a single recorded change again, to the latest version of the same file or of another one. Hunks that no longer apply are reported, the rest is recorded")
+		fmt.Println("  tag     [list|delete] [name] [filename] [revision] : Name a version of the file, the latest if no revision is given, or without a filename the latest version of every file. Tags work wherever a revision is accepted, and the tagged versions are kept by squash, prune and gc")
+		fmt.Println("  snapshots [depth] : List the most recent snapshots, the versions of every file as a command that changed the history left them. If no depth is provided, the default depth is 10")
+		fmt.Println("  checkout [snapshot] [--dry-run] [--force] : Return every file to its version in the snapshot, deleting files added since. A snapshot is a snapshot hash, \"latest~n\", a tag of every file or a time such as \"yesterday\". With --dry-run only list what would change")
+		fmt.Println("  export  [directory] [--at snapshot|time] : Write the latest recorded version of every file, or their versions in the snapshot, into a new or empty directory")
+		fmt.Println("  archive [--format tar|zip] [--at snapshot|time] [--output file] : Write the same files as export as a tar (the default) or zip archive, to stdout unless an output file is given")
+		fmt.Println("  exec    [--at snapshot|time] -- [command] [args] : Run the command in a temporary copy of the latest recorded versions, or of the snapshot, and remove the copy afterwards. athina exits with the command's exit status, or with 128 plus the signal number when the command is killed by a signal")
+		fmt.Println("  bisect  [filename|--tree] [--good revision] [--bad revision] -- [command] [args] : Find the first bad change of the file, or the first bad snapshot, by running the command against versions in between, like exec does. Exit status 0 means good, 125 skips the version, anything else means bad. A command killed by a signal stops the search. Without --good the search starts at the origin or the oldest snapshot, without --bad at the latest version")
 		fmt.Println("  list    [files|ignored] : List all files or ignored files")
+		fmt.Println("  oplog   [depth] : List the most recent operations that changed the repository. If no depth is provided, the default depth is 20")
+		fmt.Println("  undo    [n] [--force] : Roll back the last n operations (default 1), including the working files written by them. Undo never goes back past a branch switch")
+		fmt.Println("  config  [list|get|set|unset] [--global] [key] [value] : Show or change the configuration. Values in the repository config override the user's global config (" + globalConfigPath() + ")")
 		fmt.Println("  help:   Display this help message")
+		fmt.Println("Revisions:")
+		fmt.Println("  A full hash, a tag, a unique prefix of a hash, \"latest\" or \"origin\", optionally followed by ~n to go n changes further back (e.g. \"latest~3\")")
+		fmt.Println("Exit codes:")
+		fmt.Println("  0  Success")
+		fmt.Println("  1  General failure")
+		fmt.Println("  2  Invalid usage")
+		fmt.Println("  3  Not inside of an Athina repository")
+		fmt.Println("  4  File is not tracked")
+		fmt.Println("  5  Hash not found in the file's history")
+		fmt.Println("  6  Corrupt object in .athina")
+		fmt.Println("  7  Working file has unrecorded changes")
+		fmt.Println("  8  A pre-operation hook rejected the operation")
+		fmt.Println("  9  Invalid configuration")
+		fmt.Println("  10 Ambiguous revision")
+		fmt.Println("  11 A merge left conflicts to resolve")
+		fmt.Println("  12 No such trash entry")
+		fmt.Println("  13 No such branch")
+		fmt.Println("  14 No such tag")
+		fmt.Println("  The exit status of the command, when a command run by exec fails, which can coincide with the codes above. exec only uses those when the command could not be run")
+		fmt.Println("Hooks:")
+		fmt.Println("  Executables in .athina/hooks named pre-update, post-update, pre-revert or post-revert are run around the matching operation.")
+		fmt.Println("  They get the affected filenames as arguments and one \"<hash> <filename>\" line per file on stdin.")
+		fmt.Println("  A pre-hook exiting with a non-zero status aborts the operation")
 
 	case "revert":
-		if len(args) < 3 {
-			fmt.Println("Usage: athina revert [filename] [hash]")
-			return
+		file, spec, err := parseFileVersionArgs(args[1:], "athina revert [filename] [revision] | athina revert [filename] --at [time]")
+		if err != nil {
+			return err
+		}
+
+		if spec.isEmpty() {
+			return &UsageError{Usage: "athina revert [filename] [revision] | athina revert [filename] --at [time]"}
+		}
+
+		// Find the hash of the selected version, so that the revert itself always refers to an exact hash
+		athinafile, err := loadAthinaFileObject(file)
+		if err != nil {
+			return err
+		}
+
+		index, err := resolveVersion(athinafile, spec)
+		if err != nil {
+			return err
+		}
+		hash := athinafile.Diffs[index].Hash
+
+		err = AthinaRevertFileByHash(file, hash)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("File \"" + file + "\" has been reverted to hash \"" + hash + "\"")
+
+	case "show":
+		file, spec, err := parseFileVersionArgs(args[1:], SHOW_USAGE)
+		if err != nil {
+			return err
 		}
 
-		err := AthinaRevertFileByHash(args[1], args[2])
+		return withPager(func() error {
+			return AthinaShowFile(file, spec)
+		})
+
+	case "diff":
+		file, spec, err := parseFileVersionArgs(args[1:], DIFF_USAGE)
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 
-		fmt.Println("File \"" + args[1] + "\" has been reverted to hash \"" + args[2] + "\"")
+		return withPager(func() error {
+			return AthinaDiffFile(file, spec)
+		})
+
+	case "restore":
+		if len(args) < 2 || len(args) > 3 {
+			return &UsageError{Usage: "athina restore [filename] [revision]"}
+		}
+
+		file, err := resolveRepositoryPath(args[1])
+		if err != nil {
+			return err
+		}
+
+		hash := ""
+		if len(args) == 3 {
+			hash = args[2]
+		}
+
+		err = AthinaRestoreFile(file, hash)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("File \"" + file + "\" has been restored")
 
 	case "init":
-		initializeAthinaFolder()
+		err := initializeAthinaFolder()
+		if err != nil {
+			return err
+		}
 		fmt.Println("Athina has been initialized")
 	case "reset":
+		yes := false
+		var filenames []string
+		for _, arg := range args[1:] {
+			if arg == "--yes" || arg == "-y" {
+				yes = true
+			} else {
+				filenames = append(filenames, arg)
+			}
+		}
+
 		// Reset a file
-		if len(args) >= 2 {
-			for _, file := range args[1:] {
+		if len(filenames) >= 1 {
+			files, err := resolveRepositoryPaths(filenames)
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
 				err := AthinaResetFile(file)
 				if err != nil {
-					fmt.Println(err)
-					return
+					return err
 				}
 				fmt.Println("File \"" + file + "\" has been reset")
 			}
-			return
+			return nil
+		}
+
+		// Resetting the whole repository is easy to do by accident, so make sure that it is what the user wants
+		if !yes && !confirm("This moves the history of every file into the trash. Are you sure?") {
+			fmt.Println("Reset has been cancelled")
+			return nil
 		}
 
-		resetAthinaRepository()
-		fmt.Println("Athina has been reset")
+		err := resetAthinaRepository()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Athina has been reset, the old history can be restored with 'athina trash restore'")
 	case "history":
-		if len(args) == 3 {
-			depth, err := strconv.Atoi(args[2])
+		from, _, rest, err := extractFlagValue(args[1:], "--from")
+		if err != nil {
+			return err
+		}
+
+		at, _, rest, err := extractFlagValue(rest, "--at")
+		if err != nil {
+			return err
+		}
+
+		if len(rest) < 1 || len(rest) > 2 || (from != "" && at != "") {
+			return &UsageError{Usage: HISTORY_USAGE}
+		}
+
+		file, err := resolveRepositoryPath(rest[0])
+		if err != nil {
+			return err
+		}
+
+		depth := config.History.Depth
+		if len(rest) == 2 {
+			depth, err = strconv.Atoi(rest[1])
 			if err != nil {
-				fmt.Println("Depth must be an integer, but got: " + args[2])
-				return
+				return &UsageError{Usage: HISTORY_USAGE + " (depth must be an integer, but got: " + rest[1] + ")"}
 			}
-			printFileHistory(args[1], depth)
-		} else {
-			printFileHistory(args[1], DEFAULT_HISTORY_DEPTH)
 		}
 
+		return withPager(func() error {
+			return printFileHistory(file, depth, versionSpec{revision: from, at: at})
+		})
+
+	case "blame":
+		file, spec, err := parseFileVersionArgs(args[1:], "athina blame [filename] [revision] | athina blame [filename] --at [time]")
+		if err != nil {
+			return err
+		}
+
+		return withPager(func() error {
+			return printFileBlame(file, spec)
+		})
+
+	case "grep":
+		return handleGrepCommand(args[1:])
+
+	case "squash":
+		return handleSquashCommand(args[1:])
+
+	case "prune":
+		return handlePruneCommand(args[1:])
+
+	case "gc":
+		return handleGCCommand(args[1:])
+
+	case "mv":
+		return handleMvCommand(args[1:])
+
+	case "cp":
+		return handleCpCommand(args[1:])
+
+	case "branch":
+		return handleBranchCommand(args[1:])
+
+	case "switch":
+		return handleSwitchCommand(args[1:])
+
+	case "merge":
+		return handleMergeCommand(args[1:])
+
+	case "resolve":
+		return handleResolveCommand(args[1:])
+
+	case "pick":
+		return handlePickCommand(args[1:])
+
+	case "tag":
+		return handleTagCommand(args[1:])
+
+	case "snapshots":
+		return handleSnapshotsCommand(args[1:])
+
+	case "checkout":
+		return handleCheckoutCommand(args[1:])
+
+	case "export":
+		return handleExportCommand(args[1:])
+
+	case "archive":
+		return handleArchiveCommand(args[1:])
+
+	case "exec":
+		return handleExecCommand(args[1:])
+
+	case "bisect":
+		return handleBisectCommand(args[1:])
+
 	case "ignore":
-		for _, file := range args[1:] {
-			addFileToIgnoreList(file)
+		files, err := resolveRepositoryPaths(args[1:])
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			err := addFileToIgnoreList(file)
+			if err != nil {
+				return err
+			}
 			fmt.Println("File \"" + file + "\" has been added to the ignore list")
 		}
 
 	case "list":
 		if len(args) == 1 {
-			fmt.Println("Usage: athina list [files|ignored]")
-			return
+			return &UsageError{Usage: "athina list [files|ignored]"}
 		}
 
 		if args[1] == "files" {
-			for _, file := range AthinaListFiles() {
+			files, err := AthinaListFiles()
+			if err != nil {
+				return err
+			}
+
+			for _, file := range files {
 				fmt.Println(file)
 			}
 		} else if args[1] == "ignored" {
@@ -249,12 +597,117 @@ func handleCLI(args []string) {
 				fmt.Println(ignored)
 			}
 		} else {
+			return &UsageError{Usage: "athina list [files|ignored]"}
+		}
+
+	case "config":
+		return handleConfigCommand(args[1:])
+
+	case "trash":
+		return handleTrashCommand(args[1:])
+
+	case "oplog":
+		depth := DEFAULT_OPLOG_DEPTH
+		if len(args) == 2 {
+			var err error
+			depth, err = strconv.Atoi(args[1])
+			if err != nil {
+				return &UsageError{Usage: "athina oplog [depth] (depth must be an integer, but got: " + args[1] + ")"}
+			}
+		} else if len(args) > 2 {
+			return &UsageError{Usage: "athina oplog [depth]"}
+		}
+
+		return withPager(func() error {
+			return printOperationLog(depth)
+		})
+
+	case "undo":
+		n := 1
+		force := false
+		for _, arg := range args[1:] {
+			if arg == "--force" {
+				force = true
+				continue
+			}
+
+			var err error
+			n, err = strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return &UsageError{Usage: "athina undo [n] [--force]"}
+			}
+		}
+
+		operations, err := undoOperations(n, force)
+		if err != nil {
+			return err
+		}
+
+		for _, operation := range operations {
+			fmt.Printf("Operation %d (athina %s) has been undone\n", operation.ID, strings.Join(operation.Command, " "))
+		}
 
-			fmt.Println("Usage: athina list [files|ignored]")
-			return
+	default:
+		return &UsageError{Usage: "athina [command] [args]"}
+	}
+
+	return nil
+}
+
+const CONFIG_USAGE = "athina config [list | get [--show-origin] key | set [--global] key value | unset [--global] key]"
+
+func handleConfigCommand(args []string) error {
+
+	if len(args) == 0 {
+		return &UsageError{Usage: CONFIG_USAGE}
+	}
+
+	// Writes go to the repository config, unless --global is passed
+	source := CONFIG_SOURCE_REPOSITORY
+	showOrigin := false
+	var rest []string
+	for _, arg := range args[1:] {
+		switch arg {
+		case "--global":
+			source = CONFIG_SOURCE_GLOBAL
+		case "--show-origin":
+			showOrigin = true
+		default:
+			rest = append(rest, arg)
 		}
+	}
+
+	switch {
+	case args[0] == "list" && len(rest) == 0:
+		for _, key := range sortedConfigKeys() {
+			value, err := getConfigValue(key)
+			if err != nil {
+				return err
+			}
+			fmt.Println(key + "=" + value + "\t" + colorize(COLOR_CYAN, describeConfigSource(key)))
+		}
+
+	case args[0] == "get" && len(rest) == 1:
+		value, err := getConfigValue(rest[0])
+		if err != nil {
+			return err
+		}
+
+		if showOrigin {
+			fmt.Println(value + "\t" + describeConfigSource(rest[0]))
+		} else {
+			fmt.Println(value)
+		}
+
+	case args[0] == "set" && len(rest) == 2:
+		return setConfigValue(source, rest[0], rest[1])
+
+	case args[0] == "unset" && len(rest) == 1:
+		return unsetConfigValue(source, rest[0])
 
 	default:
-		fmt.Println("Usage: athina [command] [args]")
+		return &UsageError{Usage: CONFIG_USAGE}
 	}
+
+	return nil
 }
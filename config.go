@@ -4,32 +4,503 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// The effective configuration, merged from the user's global config and the repository's config
 type Config struct {
-	Ignored []string
+	Ignored   []string        `json:"ignored"`
+	User      UserConfig      `json:"user"`
+	Diff      DiffConfig      `json:"diff"`
+	History   HistoryConfig   `json:"history"`
+	Color     string          `json:"color"`
+	Pager     string          `json:"pager"`
+	Hooks     HooksConfig     `json:"hooks"`
+	Retention RetentionConfig `json:"retention"`
+	Prune     PruneConfig     `json:"prune"`
+	GC        GCConfig        `json:"gc"`
+	Rename    RenameConfig    `json:"rename"`
+	Copy      CopyConfig      `json:"copy"`
 }
 
-func (c Config) Save() error {
+type UserConfig struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type DiffConfig struct {
+	Timeout    float64 `json:"timeout"`
+	CheckLines bool    `json:"checklines"`
+}
+
+type HistoryConfig struct {
+	Depth int `json:"depth"`
+}
+
+type HooksConfig struct {
+	Enabled  bool     `json:"enabled"`
+	Disabled []string `json:"disabled"`
+}
+
+type RetentionConfig struct {
+	Rules []string `json:"rules"`
+}
+
+type PruneConfig struct {
+	OnUpdate bool `json:"onupdate"`
+}
+
+type GCConfig struct {
+	DeletedDays int `json:"deleteddays"`
+	OplogDays   int `json:"oplogdays"`
+}
+
+type RenameConfig struct {
+	Threshold float64 `json:"threshold"`
+}
+
+type CopyConfig struct {
+	Threshold float64 `json:"threshold"`
+}
+
+const DEFAULT_HISTORY_DEPTH int = 5
+
+// Environment variable that overrides where the user's global config is read from
+const ATHINA_GLOBAL_CONFIG_ENV = "ATHINA_GLOBAL_CONFIG"
+
+const (
+	CONFIG_SOURCE_DEFAULT    = "default"
+	CONFIG_SOURCE_GLOBAL     = "global"
+	CONFIG_SOURCE_REPOSITORY = "repository"
+)
+
+type configKind int
+
+const (
+	configKindString configKind = iota
+	configKindInt
+	configKindFloat
+	configKindBool
+	configKindList
+)
+
+type configKey struct {
+	kind   configKind
+	value  any
+	values []string
+	item   func(string) error
+}
+
+// Every key that may appear in a config file, together with its default value.
+// Keys are dotted paths into the nested json objects of the config files
+var configKeys = map[string]configKey{
+	"ignored":          {kind: configKindList, value: []any{}},
+	"user.name":        {kind: configKindString, value: ""},
+	"user.email":       {kind: configKindString, value: ""},
+	"diff.timeout":     {kind: configKindFloat, value: 1.0},
+	"diff.checklines":  {kind: configKindBool, value: false},
+	"history.depth":    {kind: configKindInt, value: float64(DEFAULT_HISTORY_DEPTH)},
+	"color":            {kind: configKindString, value: "auto", values: []string{"auto", "always", "never"}},
+	"pager":            {kind: configKindString, value: ""},
+	"hooks.enabled":    {kind: configKindBool, value: true},
+	"hooks.disabled":   {kind: configKindList, value: []any{}},
+	"retention.rules":  {kind: configKindList, value: []any{}, item: validateRetentionRule},
+	"prune.onupdate":   {kind: configKindBool, value: false},
+	"gc.deleteddays":   {kind: configKindInt, value: float64(DEFAULT_GC_DELETED_DAYS)},
+	"gc.oplogdays":     {kind: configKindInt, value: float64(DEFAULT_GC_OPLOG_DAYS)},
+	"rename.threshold": {kind: configKindFloat, value: DEFAULT_RENAME_THRESHOLD},
+	"copy.threshold":   {kind: configKindFloat, value: 0.0},
+}
+
+// A single config file, with its values flattened into dotted keys
+type configLayer struct {
+	source string
+	path   string
+	values map[string]any
+}
+
+// The loaded layers (lowest precedence first), the effective value of every key and which layer(s) it came from
+var configLayers []configLayer
+var configValues map[string]any
+var configSources map[string]string
+
+func globalConfigPath() string {
 
-	// Convert the File object to a Json object
-	file, err := os.Create(ATHINA_CONFIG)
+	if path := os.Getenv(ATHINA_GLOBAL_CONFIG_ENV); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return ""
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(c)
+	return filepath.Join(dir, "athina", "config.json")
+}
+
+// Loads the global config and, if we are inside of a repository, the repository's config, and merges them into config
+func loadConfig(inRepository bool) error {
+
+	configLayers = nil
+
+	global, err := loadConfigLayer(CONFIG_SOURCE_GLOBAL, globalConfigPath())
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
+	configLayers = append(configLayers, global)
+
+	if inRepository {
+		repository, err := loadConfigLayer(CONFIG_SOURCE_REPOSITORY, ATHINA_CONFIG)
+		if err != nil {
+			return err
+		}
+		configLayers = append(configLayers, repository)
+	}
+
+	return mergeConfigLayers()
+}
+
+func loadConfigLayer(source string, path string) (configLayer, error) {
+
+	layer := configLayer{source: source, path: path, values: map[string]any{}}
+	if path == "" {
+		return layer, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return layer, nil
+	}
+	if err != nil {
+		return layer, &ConfigError{Path: path, Err: err}
+	}
+
+	var nested map[string]any
+	err = json.Unmarshal(content, &nested)
+	if err != nil {
+		return layer, &ConfigError{Path: path, Err: err}
+	}
+
+	err = flattenConfig("", nested, layer.values)
+	if err != nil {
+		return layer, &ConfigError{Path: path, Err: err}
+	}
+
+	return layer, nil
+}
+
+// Flattens nested json objects into dotted keys, and validates every value against configKeys
+func flattenConfig(prefix string, nested map[string]any, flat map[string]any) error {
+
+	for name, value := range nested {
+		key := prefix + strings.ToLower(name)
+
+		if definition, ok := configKeys[key]; ok {
+			// Older versions of athina wrote an empty ignore list as null
+			if value == nil && definition.kind == configKindList {
+				value = []any{}
+			}
+
+			err := validateConfigValue(key, definition, value)
+			if err != nil {
+				return err
+			}
+			flat[key] = value
+			continue
+		}
+
+		child, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+
+		err := flattenConfig(key+".", child, flat)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+func validateConfigValue(key string, definition configKey, value any) error {
+
+	valid := false
+	switch definition.kind {
+	case configKindString:
+		s, ok := value.(string)
+		valid = ok && (definition.values == nil || slices.Contains(definition.values, s))
+	case configKindInt:
+		n, ok := value.(float64)
+		valid = ok && n == float64(int(n))
+	case configKindFloat:
+		_, valid = value.(float64)
+	case configKindBool:
+		_, valid = value.(bool)
+	case configKindList:
+		items, ok := value.([]any)
+		valid = ok
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				valid = false
+				continue
+			}
+			if definition.item != nil {
+				if err := definition.item(s); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if !valid {
+		if definition.values != nil {
+			return fmt.Errorf("invalid value %v for config key %q, expected one of %s", value, key, strings.Join(definition.values, ", "))
+		}
+		return fmt.Errorf("invalid value %v for config key %q", value, key)
+	}
+
+	return nil
+}
+
+// Merges the defaults and every layer into config. Later layers override earlier ones, except for lists which are concatenated
+func mergeConfigLayers() error {
+
+	merged := map[string]any{}
+	configSources = map[string]string{}
+
+	for key, definition := range configKeys {
+		merged[key] = definition.value
+		configSources[key] = CONFIG_SOURCE_DEFAULT
+	}
+
+	for _, layer := range configLayers {
+		for key, value := range layer.values {
+
+			if configKeys[key].kind == configKindList && configSources[key] != CONFIG_SOURCE_DEFAULT {
+				merged[key] = appendUniqueConfigItems(merged[key].([]any), value.([]any))
+				configSources[key] += "+" + layer.source
+				continue
+			}
+
+			merged[key] = value
+			configSources[key] = layer.source
+		}
+	}
+
+	configValues = merged
+
+	// Round-trip the merged values through json to fill in the typed Config struct
+	content, err := json.Marshal(unflattenConfig(merged))
+	if err != nil {
+		return err
+	}
+
+	config = Config{}
+	return json.Unmarshal(content, &config)
+}
+
+func appendUniqueConfigItems(items []any, extra []any) []any {
+
+	merged := slices.Clone(items)
+	for _, item := range extra {
+		if !slices.Contains(merged, item) {
+			merged = append(merged, item)
+		}
+	}
+
+	return merged
+}
+
+func unflattenConfig(flat map[string]any) map[string]any {
+
+	nested := map[string]any{}
+	for key, value := range flat {
+		parts := strings.Split(key, ".")
+
+		current := nested
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := current[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				current[part] = child
+			}
+			current = child
+		}
+
+		current[parts[len(parts)-1]] = value
+	}
+
+	return nested
+}
+
+func (layer configLayer) Save() error {
+
+	if layer.path == "" {
+		return &ConfigError{Path: layer.source, Err: fmt.Errorf("no location for the %s config", layer.source)}
+	}
+
+	err := os.MkdirAll(filepath.Dir(layer.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(unflattenConfig(layer.values), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(layer.path, append(content, '\n'), 0644)
+}
+
+// Returns the layer that 'config set' and friends should write to
+func getConfigLayer(source string) (*configLayer, error) {
+
+	for i := range configLayers {
+		if configLayers[i].source == source {
+			return &configLayers[i], nil
+		}
+	}
+
+	return nil, ErrRepositoryMissing
+}
+
+// Parses a value given on the command line according to the kind of the key
+func parseConfigValue(key string, raw string) (any, error) {
+
+	definition, ok := configKeys[key]
+	if !ok {
+		return nil, &ConfigError{Path: key, Err: fmt.Errorf("unknown config key %q", key)}
+	}
+
+	var value any
+	var err error
+	switch definition.kind {
+	case configKindString:
+		value = raw
+	case configKindInt:
+		var n int
+		n, err = strconv.Atoi(raw)
+		value = float64(n)
+	case configKindFloat:
+		value, err = strconv.ParseFloat(raw, 64)
+	case configKindBool:
+		value, err = strconv.ParseBool(raw)
+	case configKindList:
+		items := []any{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = items
+	}
+
+	if err == nil {
+		err = validateConfigValue(key, definition, value)
+	}
+	if err != nil {
+		return nil, &ConfigError{Path: key, Err: err}
+	}
+
+	return value, nil
+}
+
+func setConfigValue(source string, key string, raw string) error {
+
+	layer, err := getConfigLayer(source)
+	if err != nil {
+		return err
+	}
+
+	value, err := parseConfigValue(key, raw)
+	if err != nil {
+		return err
+	}
+
+	layer.values[key] = value
+	err = layer.Save()
+	if err != nil {
+		return err
+	}
+
+	return mergeConfigLayers()
+}
+
+func unsetConfigValue(source string, key string) error {
+
+	layer, err := getConfigLayer(source)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := configKeys[key]; !ok {
+		return &ConfigError{Path: key, Err: fmt.Errorf("unknown config key %q", key)}
+	}
+
+	delete(layer.values, key)
+	err = layer.Save()
+	if err != nil {
+		return err
+	}
+
+	return mergeConfigLayers()
+}
+
+// Formats the effective value of a key the same way 'config set' accepts it
+func getConfigValue(key string) (string, error) {
+
+	value, ok := configValues[key]
+	if !ok {
+		return "", &ConfigError{Path: key, Err: fmt.Errorf("unknown config key %q", key)}
+	}
+
+	if items, ok := value.([]any); ok {
+		var parts []string
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, ","), nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+func sortedConfigKeys() []string {
+
+	var keys []string
+	for key := range configKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Describes where the value of a key came from, e.g. "repository (.athina/config.json)"
+func describeConfigSource(key string) string {
+
+	source := configSources[key]
+
+	var paths []string
+	for _, layer := range configLayers {
+		if strings.Contains(source, layer.source) {
+			paths = append(paths, layer.path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return source
+	}
+
+	return source + " (" + strings.Join(paths, ", ") + ")"
+}
+
 func (c Config) IsIgnored(filename string) bool {
 
 	for _, ignored := range c.Ignored {
@@ -40,3 +511,18 @@ func (c Config) IsIgnored(filename string) bool {
 
 	return false
 }
+
+func (c Config) IsHookEnabled(hook string) bool {
+	return c.Hooks.Enabled && !slices.Contains(c.Hooks.Disabled, hook)
+}
+
+// Returns the identity that is recorded with every change, e.g. "Jane Doe <jane@example.com>"
+func (c Config) Identity() string {
+
+	identity := c.User.Name
+	if c.User.Email != "" {
+		identity = strings.TrimSpace(identity + " <" + c.User.Email + ">")
+	}
+
+	return identity
+}
@@ -4,3 +4,7 @@ const ATHINA_FOLDER = ".athina"
 const ATHINA_CONFIG = ".athina/config.json"
 const ATHINA_STASH = ".athina/stash.json"
 const ATHINA_PATH_TO_OBJECTS = ".athina/objects/"
+const ATHINA_HOOKS = ".athina/hooks/"
+const ATHINA_TRASH = ".athina/trash/"
+const ATHINA_OPLOG = ".athina/oplog.jsonl"
+const ATHINA_BLOBS = ".athina/blobs/"
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const CP_USAGE = "athina cp [source filename] [new filename]"
+
+// Seeds the history of a new file with the full history of a tracked one, and records where the two diverged
+// together with the given delta. The working files are left alone
+func AthinaCopyFileHistory(source string, target string, delta string) error {
+
+	athinafile, err := loadAthinaFileObject(source)
+	if err != nil {
+		return err
+	}
+
+	if athinaObjectExists(target) {
+		return &FileError{Filename: target, Err: fmt.Errorf("%w, it is already tracked", fs.ErrExist)}
+	}
+
+	filediff := newFilediff(newFileDiffOptions{change: AthinaFileChangeActionCopy, delta: delta, from: source})
+
+	copied := AthinaFile{Filename: target, Origin: athinafile.Origin, Diffs: slices.Clone(athinafile.Diffs)}
+
+	slog.Debug("copying file", "from", source, "to", target, "hash", filediff.Hash)
+
+	return AthinaModifyFile(copied, []Filediff{filediff})
+}
+
+// Copies a tracked file, both the working file and its history, to a new name
+func AthinaCopyFile(source string, target string) error {
+
+	athinafile, err := loadAthinaFileObject(source)
+	if err != nil {
+		return err
+	}
+
+	if athinafile.isDeleted() {
+		return &FileError{Filename: source, Err: fmt.Errorf("%w, the file has been deleted", fs.ErrNotExist)}
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		return &FileError{Filename: target, Err: fs.ErrExist}
+	}
+
+	if athinaObjectExists(target) {
+		return &FileError{Filename: target, Err: fmt.Errorf("%w, it is already tracked", fs.ErrExist)}
+	}
+
+	content, err := os.ReadFile(source)
+	if err != nil {
+		return &FileError{Filename: source, Err: err}
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = touchFile(target)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(target, content, 0644)
+	if err != nil {
+		return &FileError{Filename: target, Err: err}
+	}
+
+	// Just like with mv, changes that were not recorded yet show up as a modification on the next update
+	return AthinaCopyFileHistory(source, target, "")
+}
+
+// Finds the tracked file that every new file is most similar to, and pairs them up as copies when the similarity
+// reaches copy.threshold. Files in gone are renamed or deleted by the same update, and are never a source.
+// Returns the copy changes, and the additions that are left over
+func detectCopies(added []AthinaFileChange, gone []string) ([]AthinaFileChange, []AthinaFileChange, error) {
+
+	threshold := config.Copy.Threshold
+	if threshold <= 0 || len(added) == 0 {
+		return nil, added, nil
+	}
+
+	filenames, err := AthinaListFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The latest recorded content of every tracked file that still exists, in the history and in the working directory
+	sources := map[string]string{}
+	for _, filename := range filenames {
+		if slices.Contains(gone, filename) {
+			continue
+		}
+
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if athinafile.isDeleted() {
+			continue
+		}
+
+		sources[filename], err = emulateDeltaDiffsFromAthinaFileObject(athinafile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var copies, remaining []AthinaFileChange
+	for _, change := range added {
+		content, err := os.ReadFile(change.filename)
+		if err != nil {
+			return nil, nil, &FileError{Filename: change.filename, Err: err}
+		}
+
+		best, bestSource := threshold, ""
+		for _, filename := range filenames {
+			source, ok := sources[filename]
+			if !ok {
+				continue
+			}
+			if score := similarity(source, string(content)); score >= best && (bestSource == "" || score > best) {
+				best, bestSource = score, filename
+			}
+		}
+
+		if bestSource == "" {
+			remaining = append(remaining, change)
+			continue
+		}
+
+		slog.Debug("detected copy", "from", bestSource, "to", change.filename, "similarity", best)
+		copies = append(copies, AthinaFileChange{action: AthinaFileChangeActionCopy, from: bestSource, filename: change.filename})
+	}
+
+	return copies, remaining, nil
+}
+
+func handleCpCommand(args []string) error {
+
+	if len(args) != 2 {
+		return &UsageError{Usage: CP_USAGE}
+	}
+
+	files, err := resolveRepositoryPaths(args)
+	if err != nil {
+		return err
+	}
+
+	if files[0] == files[1] {
+		return &UsageError{Usage: CP_USAGE + " (the new filename has to differ from the source)"}
+	}
+
+	err = AthinaCopyFile(files[0], files[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("File \"" + files[0] + "\" has been copied to \"" + files[1] + "\"")
+
+	return nil
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
-	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
+// Returns a diffmatchpatch instance that is set up with the diff options from the config
+func newDiffMatchPatch() *diffmatchpatch.DiffMatchPatch {
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = time.Duration(config.Diff.Timeout * float64(time.Second))
+
+	return dmp
+}
+
 func emulateDeltaDiffsFromAthinaFileObject(athinafile AthinaFile) (string, error) {
 
 	dmp := diffmatchpatch.New()
@@ -22,8 +32,7 @@ func emulateDeltaDiffsFromAthinaFileObject(athinafile AthinaFile) (string, error
 
 		new_diff, err := dmp.DiffFromDelta(origin, filediff.Delta)
 		if err != nil {
-			fmt.Println(err)
-			return "", err
+			return "", &CorruptObjectError{Filename: athinafile.Filename, Err: err}
 		}
 
 		origin = dmp.DiffText2(new_diff)
@@ -41,13 +50,12 @@ func diffAthinaFileObjectAndString(athinafile AthinaFile, filestring string) (st
 	// First, we want to reconstruct the current state of the Athina File
 	current_state, err := emulateDeltaDiffsFromAthinaFileObject(athinafile)
 	if err != nil {
-		fmt.Println(err)
 		return "", err
 	}
 
 	// Then, we want to compare the current state of the Athina File with the current state of the file in the directory
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(current_state, filestring, false)
+	dmp := newDiffMatchPatch()
+	diffs := dmp.DiffMain(current_state, filestring, config.Diff.CheckLines)
 	delta := dmp.DiffToDelta(diffs)
 	return delta, nil
 }
@@ -57,8 +65,7 @@ func diffAthinaFileObjectAndFile(athinafile AthinaFile, filename string) (string
 
 	file, err := os.Open(filename)
 	if err != nil {
-		fmt.Println(err)
-		return "", err
+		return "", &FileError{Filename: filename, Err: err}
 	}
 
 	defer file.Close()
@@ -66,16 +73,14 @@ func diffAthinaFileObjectAndFile(athinafile AthinaFile, filename string) (string
 	// Read the content of the file
 	fileinfo, err := file.Stat()
 	if err != nil {
-		fmt.Println(err)
-		return "", err
+		return "", &FileError{Filename: filename, Err: err}
 	}
 
 	filesize := fileinfo.Size()
 	filecontent := make([]byte, filesize)
 	_, err = file.Read(filecontent)
-	if err != nil {
-		fmt.Println(err)
-		return "", err
+	if err != nil && filesize > 0 {
+		return "", &FileError{Filename: filename, Err: err}
 	}
 
 	// Convert the content of the file to a string
@@ -84,3 +89,122 @@ func diffAthinaFileObjectAndFile(athinafile AthinaFile, filename string) (string
 	// Compare the AthinaFile object with the file in the directory
 	return diffAthinaFileObjectAndString(athinafile, filestring)
 }
+
+// Reconstructs the content of the file as it was right after the Filediff with the given hash (or any other revision)
+func emulateDeltaDiffsUntilHash(athinafile AthinaFile, hash string) (string, error) {
+
+	index, err := resolveRevision(athinafile, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return emulateDeltaDiffsUntilIndex(athinafile, index)
+}
+
+// Reconstructs the content of the file as it was right after the Filediff at the given index
+func emulateDeltaDiffsUntilIndex(athinafile AthinaFile, index int) (string, error) {
+
+	athinafile.Diffs = athinafile.Diffs[:index+1]
+	return emulateDeltaDiffsFromAthinaFileObject(athinafile)
+}
+
+// A run of whole lines that were kept, inserted or deleted
+type lineDiff struct {
+	Type  diffmatchpatch.Operation
+	Lines []string
+}
+
+// Splits text into lines, every line keeps its line ending
+func splitLines(text string) []string {
+
+	var lines []string
+	for len(text) > 0 {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+
+	return lines
+}
+
+// Diffs two texts line by line, by encoding every distinct line as a single rune and diffing those
+func diffLines(text1 string, text2 string) []lineDiff {
+
+	var lineArray []string
+	lineIndex := map[string]rune{}
+
+	encode := func(text string) []rune {
+		var runes []rune
+		for _, line := range splitLines(text) {
+			r, ok := lineIndex[line]
+			if !ok {
+				// Skip over the surrogate range, which does not hold valid runes
+				r = rune(len(lineArray) + 1)
+				if r >= 0xD800 {
+					r += 0x800
+				}
+				lineIndex[line] = r
+				lineArray = append(lineArray, line)
+			}
+			runes = append(runes, r)
+		}
+		return runes
+	}
+
+	decode := func(r rune) string {
+		if r >= 0xD800 {
+			r -= 0x800
+		}
+		return lineArray[r-1]
+	}
+
+	runes1 := encode(text1)
+	runes2 := encode(text2)
+
+	dmp := newDiffMatchPatch()
+	var diffs []lineDiff
+	for _, diff := range dmp.DiffMainRunes(runes1, runes2, false) {
+		var lines []string
+		for _, r := range diff.Text {
+			lines = append(lines, decode(r))
+		}
+		diffs = append(diffs, lineDiff{Type: diff.Type, Lines: lines})
+	}
+
+	return diffs
+}
+
+// Replays the Filediffs of the AthinaFile in order and calls fn with the content of the file before and after every one of them.
+// The first Filediff goes from an empty file to the origin, Filediffs that do not change the content get identical before and after
+func replayAthinaFile(athinafile AthinaFile, fn func(index int, filediff Filediff, before string, after string) error) error {
+
+	dmp := diffmatchpatch.New()
+	current := ""
+
+	for i, filediff := range athinafile.Diffs {
+
+		next := current
+		if i == 0 {
+			next = athinafile.Origin
+		} else if !(filediff.Deleted && filediff.Added) && !isDeltaDiffEmpty(filediff.Delta) {
+			diffs, err := dmp.DiffFromDelta(current, filediff.Delta)
+			if err != nil {
+				return &CorruptObjectError{Filename: athinafile.Filename, Err: err}
+			}
+			next = dmp.DiffText2(diffs)
+		}
+
+		err := fn(i, filediff, current, next)
+		if err != nil {
+			return err
+		}
+
+		current = next
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// Sentinel errors that callers can test for with errors.Is
+var (
+	ErrNotTracked         = errors.New("file is not tracked by athina")
+	ErrHashNotFound       = errors.New("no such hash found")
+	ErrRepositoryMissing  = errors.New("not an athina repository (no .athina folder found)")
+	ErrCorruptObject      = errors.New("corrupt athina object")
+	ErrDirtyWorkingFile   = errors.New("working file has unrecorded changes")
+	ErrHookFailed         = errors.New("hook failed")
+	ErrInvalidConfig      = errors.New("invalid config")
+	ErrTrashEntryNotFound = errors.New("no such trash entry")
+	ErrAmbiguousRevision  = errors.New("ambiguous revision")
+	ErrBranchNotFound     = errors.New("no such branch")
+	ErrMergeConflict      = errors.New("merge conflict")
+	ErrTagNotFound        = errors.New("no such tag")
+)
+
+// Process exit codes, one per error kind so that shell scripts can react to them
+const (
+	EXIT_OK                 int = 0
+	EXIT_FAILURE            int = 1
+	EXIT_USAGE              int = 2
+	EXIT_REPOSITORY_MISSING int = 3
+	EXIT_NOT_TRACKED        int = 4
+	EXIT_HASH_NOT_FOUND     int = 5
+	EXIT_CORRUPT_OBJECT     int = 6
+	EXIT_DIRTY_WORKING_FILE int = 7
+	EXIT_HOOK_FAILED        int = 8
+	EXIT_INVALID_CONFIG     int = 9
+	EXIT_AMBIGUOUS_REVISION int = 10
+	EXIT_MERGE_CONFLICT     int = 11
+	EXIT_TRASH_NOT_FOUND    int = 12
+	EXIT_BRANCH_NOT_FOUND   int = 13
+	EXIT_TAG_NOT_FOUND      int = 14
+)
+
+// FileError ties an error to the file it happened on
+type FileError struct {
+	Filename string
+	Err      error
+}
+
+func (e *FileError) Error() string {
+	return e.Filename + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// CorruptObjectError is returned when an object in .athina/objects can not be decoded or replayed
+type CorruptObjectError struct {
+	Filename string
+	Err      error
+}
+
+func (e *CorruptObjectError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Filename, ErrCorruptObject.Error(), e.Err.Error())
+}
+
+func (e *CorruptObjectError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CorruptObjectError) Is(target error) bool {
+	return target == ErrCorruptObject
+}
+
+// ConfigError is returned when a config file (or a value given for it) is malformed
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Path, ErrInvalidConfig.Error(), e.Err.Error())
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// AmbiguousRevisionError is returned when a hash prefix matches more than one Filediff
+type AmbiguousRevisionError struct {
+	Filename   string
+	Revision   string
+	Candidates []string
+}
+
+func (e *AmbiguousRevisionError) Error() string {
+	return fmt.Sprintf("%s: %s %q, it matches %s", e.Filename, ErrAmbiguousRevision.Error(), e.Revision, strings.Join(e.Candidates, ", "))
+}
+
+func (e *AmbiguousRevisionError) Is(target error) bool {
+	return target == ErrAmbiguousRevision
+}
+
+// UsageError is returned when a command is invoked with the wrong arguments
+type UsageError struct {
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	return "Usage: " + e.Usage
+}
+
+// CommandExitError is returned when a command run by athina, e.g. with 'athina exec', exits with a non-zero status or is killed by a signal.
+// athina exits with the same status, or like a shell with 128 plus the signal number. These statuses are the command's and can coincide
+// with athina's own exit codes, which exec only uses when the command could not be run at all
+type CommandExitError struct {
+	Command string
+	Code    int
+	Signal  syscall.Signal
+}
+
+func (e *CommandExitError) Error() string {
+
+	if e.Signal != 0 {
+		return fmt.Sprintf("%s: killed by signal %d (%s)", e.Command, int(e.Signal), e.Signal)
+	}
+
+	return fmt.Sprintf("%s: exited with status %d", e.Command, e.Code)
+}
+
+func exitCodeForError(err error) int {
+
+	var usage *UsageError
+	var commandExit *CommandExitError
+
+	switch {
+	case err == nil:
+		return EXIT_OK
+	case errors.As(err, &commandExit):
+		return commandExit.Code
+	case errors.As(err, &usage):
+		return EXIT_USAGE
+	case errors.Is(err, ErrRepositoryMissing):
+		return EXIT_REPOSITORY_MISSING
+	case errors.Is(err, ErrNotTracked):
+		return EXIT_NOT_TRACKED
+	case errors.Is(err, ErrHashNotFound):
+		return EXIT_HASH_NOT_FOUND
+	case errors.Is(err, ErrCorruptObject):
+		return EXIT_CORRUPT_OBJECT
+	case errors.Is(err, ErrDirtyWorkingFile):
+		return EXIT_DIRTY_WORKING_FILE
+	case errors.Is(err, ErrHookFailed):
+		return EXIT_HOOK_FAILED
+	case errors.Is(err, ErrInvalidConfig):
+		return EXIT_INVALID_CONFIG
+	case errors.Is(err, ErrAmbiguousRevision):
+		return EXIT_AMBIGUOUS_REVISION
+	case errors.Is(err, ErrMergeConflict):
+		return EXIT_MERGE_CONFLICT
+	case errors.Is(err, ErrTrashEntryNotFound):
+		return EXIT_TRASH_NOT_FOUND
+	case errors.Is(err, ErrBranchNotFound):
+		return EXIT_BRANCH_NOT_FOUND
+	case errors.Is(err, ErrTagNotFound):
+		return EXIT_TAG_NOT_FOUND
+	}
+
+	return EXIT_FAILURE
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExitCodeForError(t *testing.T) {
+
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, EXIT_OK},
+		{errors.New("anything else"), EXIT_FAILURE},
+		{&UsageError{Usage: "athina"}, EXIT_USAGE},
+		{ErrRepositoryMissing, EXIT_REPOSITORY_MISSING},
+		{ErrNotTracked, EXIT_NOT_TRACKED},
+		{ErrHashNotFound, EXIT_HASH_NOT_FOUND},
+		{ErrCorruptObject, EXIT_CORRUPT_OBJECT},
+		{ErrDirtyWorkingFile, EXIT_DIRTY_WORKING_FILE},
+		{ErrHookFailed, EXIT_HOOK_FAILED},
+		{ErrInvalidConfig, EXIT_INVALID_CONFIG},
+		{ErrAmbiguousRevision, EXIT_AMBIGUOUS_REVISION},
+		{ErrMergeConflict, EXIT_MERGE_CONFLICT},
+		{ErrTrashEntryNotFound, EXIT_TRASH_NOT_FOUND},
+		{ErrBranchNotFound, EXIT_BRANCH_NOT_FOUND},
+		{ErrTagNotFound, EXIT_TAG_NOT_FOUND},
+		{&CommandExitError{Command: "make", Code: 3}, 3},
+	}
+
+	seen := map[int]error{}
+	for _, test := range tests {
+		// Sentinels reach the top wrapped in a FileError most of the time
+		err := test.err
+		if err != nil {
+			err = &FileError{Filename: "f.txt", Err: err}
+		}
+
+		if code := exitCodeForError(err); code != test.code {
+			t.Errorf("exitCodeForError(%v) = %d, want %d", err, code, test.code)
+		}
+
+		// Every kind of error has a code of its own, only a command run by exec may coincide with them
+		if _, ok := test.err.(*CommandExitError); ok {
+			continue
+		}
+		if other, ok := seen[test.code]; ok {
+			t.Errorf("%v and %v share exit code %d", other, test.err, test.code)
+		}
+		seen[test.code] = test.err
+	}
+}
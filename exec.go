@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+const EXEC_USAGE = "athina exec [--at snapshot|time] -- [command] [args]"
+
+// Materializes the tree into a temporary directory, runs the command there with the terminal attached, and removes the directory again.
+// Returns the exit status of the command, an error when it could not be run at all or was killed by a signal
+func runInTree(tree Tree, command []string) (int, error) {
+
+	dir, err := os.MkdirTemp("", "athina-exec-")
+	if err != nil {
+		return -1, err
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = exportAthinaTree(tree, dir)
+	if err != nil {
+		return -1, err
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "ATHINA_REPOSITORY="+repositoryRoot)
+	if tree.Hash != "" {
+		cmd.Env = append(cmd.Env, "ATHINA_SNAPSHOT="+tree.Hash)
+	}
+
+	// An interrupt is meant for the command, athina itself stays around to clean up the directory
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	slog.Debug("running command", "command", command, "dir", dir, "snapshot", tree.Hash)
+
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// A command killed by a signal has no exit status of its own
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return -1, &CommandExitError{Command: strings.Join(command, " "), Code: 128 + int(status.Signal()), Signal: status.Signal()}
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, &FileError{Filename: command[0], Err: err}
+	}
+
+	return 0, nil
+}
+
+// Splits the arguments at "--" into athina's own arguments and the command to run
+func splitCommand(args []string, usage string) ([]string, []string, error) {
+
+	i := slices.Index(args, "--")
+	if i < 0 || i == len(args)-1 {
+		return nil, nil, &UsageError{Usage: usage}
+	}
+
+	return args[:i], args[i+1:], nil
+}
+
+func handleExecCommand(args []string) error {
+
+	args, command, err := splitCommand(args, EXEC_USAGE)
+	if err != nil {
+		return err
+	}
+
+	at, _, args, err := extractFlagValue(args, "--at")
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return &UsageError{Usage: EXEC_USAGE}
+	}
+
+	tree, err := resolveExportTree(at)
+	if err != nil {
+		return err
+	}
+
+	code, err := runInTree(tree, command)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return &CommandExitError{Command: strings.Join(command, " "), Code: code}
+	}
+
+	if !options.quiet {
+		fmt.Fprintln(os.Stderr, "Command exited with status 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const EXPORT_USAGE = "athina export [directory] [--at snapshot|time]"
+const ARCHIVE_USAGE = "athina archive [--format tar|zip] [--at snapshot|time] [--output file]"
+
+const (
+	ARCHIVE_FORMAT_TAR = "tar"
+	ARCHIVE_FORMAT_ZIP = "zip"
+)
+
+// Returns the tree that --at selects, or the latest recorded version of every file when it is empty
+func resolveExportTree(at string) (Tree, error) {
+
+	if at == "" {
+		return currentTree()
+	}
+
+	return resolveSnapshot(at)
+}
+
+// Writes the content of every file in the tree below dir, which must not exist yet or be empty
+func exportAthinaTree(tree Tree, dir string) (int, error) {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, &FileError{Filename: dir, Err: err}
+	}
+	if len(entries) > 0 {
+		return 0, &FileError{Filename: dir, Err: fmt.Errorf("%w, export into an empty or new directory", fs.ErrExist)}
+	}
+
+	contents, err := treeContents(tree)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, filename := range tree.filenames() {
+		path := filepath.Join(dir, filepath.FromSlash(filename))
+
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return 0, err
+		}
+
+		slog.Debug("exporting file", "file", filename, "hash", tree.Files[filename], "to", path)
+
+		err = os.WriteFile(path, []byte(contents[filename]), 0644)
+		if err != nil {
+			return 0, &FileError{Filename: path, Err: err}
+		}
+	}
+
+	return len(contents), nil
+}
+
+// Streams the content of every file in the tree to w as a tar or zip archive
+func archiveAthinaTree(tree Tree, format string, w io.Writer) error {
+
+	contents, err := treeContents(tree)
+	if err != nil {
+		return err
+	}
+
+	// Entries carry the time of the snapshot, so that archiving the same snapshot twice gives the same archive
+	modified := tree.Time
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	switch format {
+	case ARCHIVE_FORMAT_TAR:
+		archive := tar.NewWriter(w)
+		for _, filename := range tree.filenames() {
+			content := contents[filename]
+			err := archive.WriteHeader(&tar.Header{Name: filename, Mode: 0644, Size: int64(len(content)), ModTime: modified, Typeflag: tar.TypeReg})
+			if err != nil {
+				return err
+			}
+
+			_, err = io.WriteString(archive, content)
+			if err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+
+	case ARCHIVE_FORMAT_ZIP:
+		archive := zip.NewWriter(w)
+		for _, filename := range tree.filenames() {
+			header := &zip.FileHeader{Name: filename, Method: zip.Deflate, Modified: modified}
+			header.SetMode(0644)
+
+			entry, err := archive.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.WriteString(entry, contents[filename])
+			if err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	}
+
+	return &UsageError{Usage: ARCHIVE_USAGE + " (unknown format " + format + ")"}
+}
+
+func handleExportCommand(args []string) error {
+
+	at, _, args, err := extractFlagValue(args, "--at")
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return &UsageError{Usage: EXPORT_USAGE}
+	}
+
+	tree, err := resolveExportTree(at)
+	if err != nil {
+		return err
+	}
+
+	// The exported files would show up as new files next to the tracked ones
+	dir := resolveInvocationPath(args[0])
+	if isInsideDirectory(repositoryRoot, dir) {
+		return &FileError{Filename: dir, Err: errors.New("can not export into the working tree, choose a directory outside of " + repositoryRoot)}
+	}
+
+	exported, err := exportAthinaTree(tree, dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d files to \"%s\"\n", exported, dir)
+
+	return nil
+}
+
+func handleArchiveCommand(args []string) error {
+
+	format, found, args, err := extractFlagValue(args, "--format")
+	if err != nil {
+		return err
+	}
+	if !found {
+		format = ARCHIVE_FORMAT_TAR
+	}
+
+	at, _, args, err := extractFlagValue(args, "--at")
+	if err != nil {
+		return err
+	}
+
+	output, _, args, err := extractFlagValue(args, "--output")
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		output, _, args, err = extractFlagValue(args, "-o")
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(args) > 0 || (format != ARCHIVE_FORMAT_TAR && format != ARCHIVE_FORMAT_ZIP) {
+		return &UsageError{Usage: ARCHIVE_USAGE}
+	}
+
+	tree, err := resolveExportTree(at)
+	if err != nil {
+		return err
+	}
+
+	// Without an output file the archive is streamed to stdout, e.g. to be piped into another tool
+	if output == "" {
+		return archiveAthinaTree(tree, format, os.Stdout)
+	}
+
+	output = resolveInvocationPath(output)
+	file, err := os.Create(output)
+	if err != nil {
+		return &FileError{Filename: output, Err: err}
+	}
+
+	err = archiveAthinaTree(tree, format, file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -2,10 +2,16 @@ package main
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
+// A single recorded change of a file. The Hash is computed once, when the change is recorded, and from then on identifies
+// the version of the file the change leads to, not the delta that gets there: prune rewrites the Delta of versions it keeps,
+// but their hashes, and with them tags, trees and the history shared between branches, stay the same.
+// Hashes are therefore never recomputed from a stored Filediff, nor checked against getHash
 type Filediff struct {
 	Hash    string
 	Diffs   []diffmatchpatch.Diff
@@ -13,11 +19,35 @@ type Filediff struct {
 	Deleted bool
 	Added   bool
 	Change  AthinaFileChangeAction
+	Author  string
+	Time    time.Time
+	Message string   `json:",omitempty"`
+	From    string   `json:",omitempty"`
+	Parents []string `json:",omitempty"`
 }
 
 func (f Filediff) getHash() string {
 	dmp := diffmatchpatch.New()
-	return sha1Hash(dmp.DiffPrettyText(f.Diffs) + f.Delta + strconv.FormatBool(f.Deleted) + strconv.FormatBool(f.Added) + string(f.Change))
+	hash := dmp.DiffPrettyText(f.Diffs) + f.Delta + strconv.FormatBool(f.Deleted) + strconv.FormatBool(f.Added) + string(f.Change)
+
+	// Fields that were added later only take part in the hash when they are set, so that older hashes stay reproducible
+	if f.Author != "" {
+		hash += f.Author
+	}
+	if !f.Time.IsZero() {
+		hash += f.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if f.Message != "" {
+		hash += f.Message
+	}
+	if f.From != "" {
+		hash += f.From
+	}
+	if len(f.Parents) > 0 {
+		hash += strings.Join(f.Parents, ",")
+	}
+
+	return sha1Hash(hash)
 }
 
 type newFileDiffOptions struct {
@@ -26,6 +56,9 @@ type newFileDiffOptions struct {
 	added   bool
 	change  AthinaFileChangeAction
 	delta   string
+	message string
+	from    string
+	parents []string
 }
 
 func newFilediff(options newFileDiffOptions) Filediff {
@@ -37,9 +70,35 @@ func newFilediff(options newFileDiffOptions) Filediff {
 		Deleted: options.deleted,
 		Added:   options.added,
 		Change:  options.change,
+		Author:  config.Identity(),
+		Time:    time.Now(),
+		Message: options.message,
+		From:    options.from,
+		Parents: options.parents,
 	}
 
 	filediff.Hash = filediff.getHash()
 
 	return filediff
 }
+
+const SHORT_HASH_LENGTH int = 8
+
+func shortenHash(hash string) string {
+
+	if len(hash) <= SHORT_HASH_LENGTH {
+		return hash
+	}
+
+	return hash[:SHORT_HASH_LENGTH]
+}
+
+// Formats the time of the Filediff for display, Filediffs recorded before times were tracked have none
+func (f Filediff) formatTime() string {
+
+	if f.Time.IsZero() {
+		return "-"
+	}
+
+	return f.Time.Local().Format("2006-01-02 15:04")
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
+	"path/filepath"
 )
 
 type AthinaFile struct {
@@ -14,10 +14,26 @@ type AthinaFile struct {
 
 func (f AthinaFile) Save() error {
 
+	err := touchFile(f.Filename)
+	if err != nil {
+		return err
+	}
+
+	// A saved object is always loose, so it must no longer be read from the pack
+	err = forgetPackedObject(f.Filename)
+	if err != nil {
+		return err
+	}
+
+	// Files in subdirectories of the repository are stored in the same subdirectories of .athina/objects
+	err = os.MkdirAll(filepath.Dir(objectsPath()+f.Filename), 0755)
+	if err != nil {
+		return err
+	}
+
 	// Convert the File object to a Json object
-	file, err := os.Create(ATHINA_PATH_TO_OBJECTS + f.Filename)
+	file, err := os.Create(objectsPath() + f.Filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 	defer file.Close()
@@ -25,9 +41,44 @@ func (f AthinaFile) Save() error {
 	encoder := json.NewEncoder(file)
 	err = encoder.Encode(f)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
 	return nil
 }
+
+// Returns the hash of the most recent Filediff, which identifies the current recorded state of the file
+func (f AthinaFile) getLatestHash() string {
+
+	if len(f.Diffs) == 0 {
+		return ""
+	}
+
+	return f.Diffs[len(f.Diffs)-1].Hash
+}
+
+// A file is deleted when the most recent Filediff records its deletion
+func (f AthinaFile) isDeleted() bool {
+
+	if len(f.Diffs) == 0 {
+		return false
+	}
+
+	return f.Diffs[len(f.Diffs)-1].Deleted
+}
+
+// Writes the file under a temporary name next to it and renames it into place, so that readers either see the old content or the new
+func writeFileAtomically(path string, content []byte) error {
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path+".tmp", content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
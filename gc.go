@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const GC_USAGE = "athina gc [--pack] [--dry-run]"
+
+const DEFAULT_GC_DELETED_DAYS int = 30
+const DEFAULT_GC_OPLOG_DAYS int = 90
+
+// What a garbage collection found, and with dryRun unset, dropped
+type gcResult struct {
+	deletedFiles []string
+	trashEntry   TrashEntry
+	emptyChanges int
+	stashItems   int
+	operations   int
+	blobs        int
+	packed       int
+	sizeBefore   int64
+	sizeAfter    int64
+}
+
+// Changes that record nothing: modifications with an empty delta, and entries that are both a deletion and an addition.
+// The first Filediff of a file is never empty, its content lives in the origin
+func isEmptyFilediff(index int, filediff Filediff) bool {
+
+	if index == 0 {
+		return false
+	}
+
+	if filediff.Deleted && filediff.Added {
+		return true
+	}
+
+	return filediff.Change == AthinaFileChangeActionModify && !filediff.Deleted && !filediff.Added && isDeltaDiffEmpty(filediff.Delta)
+}
+
+// Whether the file was deleted before the cutoff. Deletions recorded before times were tracked count as old
+func deletedBefore(athinafile AthinaFile, cutoff time.Time) bool {
+
+	if !athinafile.isDeleted() {
+		return false
+	}
+
+	return athinafile.Diffs[len(athinafile.Diffs)-1].Time.Before(cutoff)
+}
+
+// Moves the objects of untagged files that were deleted before the cutoff into the trash and drops their place in the snapshots, collapses empty changes,
+// drops stash items of files that are no longer tracked, trims the operation log to the operations recorded since oplogCutoff and, with pack set, packs every object
+func collectAthinaGarbage(cutoff time.Time, oplogCutoff time.Time, pack bool, dryRun bool) (gcResult, error) {
+
+	var result gcResult
+
+	size, err := directorySize(ATHINA_FOLDER)
+	if err != nil {
+		return result, err
+	}
+	result.sizeBefore = size
+
+	filenames, err := AthinaListFiles()
+	if err != nil {
+		return result, err
+	}
+
+	tracked := map[string]bool{}
+	for _, filename := range filenames {
+
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return result, err
+		}
+
+		tagged, err := taggedFilediffs(athinafile)
+		if err != nil {
+			return result, err
+		}
+
+		if deletedBefore(athinafile, cutoff) && !slices.Contains(tagged, true) {
+			result.deletedFiles = append(result.deletedFiles, filename)
+			continue
+		}
+		tracked[filename] = true
+
+		// An empty change leads to the same content as the change before it, which takes its place in the snapshots
+		var diffs []Filediff
+		replaced := map[string]string{}
+		for i, filediff := range athinafile.Diffs {
+			if isEmptyFilediff(i, filediff) && !tagged[i] {
+				result.emptyChanges++
+				replaced[filediff.Hash] = diffs[len(diffs)-1].Hash
+				continue
+			}
+			diffs = append(diffs, filediff)
+		}
+
+		if len(diffs) < len(athinafile.Diffs) && !dryRun {
+			slog.Debug("collapsing empty changes", "file", filename, "dropped", len(athinafile.Diffs)-len(diffs))
+			athinafile.Diffs = diffs
+			err = athinafile.Save()
+			if err != nil {
+				return result, err
+			}
+
+			err = retargetTrees(filename, replaced)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// The histories of deleted files all go into a single trash entry, from where 'athina trash restore' can bring them back
+	if len(result.deletedFiles) > 0 && !dryRun {
+		var paths []string
+		for _, filename := range result.deletedFiles {
+			paths = append(paths, objectsPath()+filename)
+		}
+
+		slog.Debug("moving objects of deleted files into the trash", "files", result.deletedFiles)
+		result.trashEntry, err = moveToTrash("gc", paths)
+		if err != nil {
+			return result, err
+		}
+
+		for _, filename := range result.deletedFiles {
+			err = forgetTreeFile(filename)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Stash items of files that are gone can never be applied again
+	var stashes []Commit
+	for _, commit := range stash.Stashes {
+		for _, item := range commit.Items {
+			if !tracked[item.Filename] {
+				result.stashItems++
+				commit = commit.removeCommitItem(item)
+			}
+		}
+
+		if len(commit.Items) > 0 {
+			stashes = append(stashes, commit)
+		}
+	}
+
+	if result.stashItems > 0 && !dryRun {
+		stash.Stashes = stashes
+		err = stash.Save()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.operations, result.blobs, err = trimOperationLog(oplogCutoff, dryRun)
+	if err != nil {
+		return result, err
+	}
+
+	if pack && !dryRun {
+		result.packed, err = packAthinaObjects()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	size, err = directorySize(ATHINA_FOLDER)
+	if err != nil {
+		return result, err
+	}
+	result.sizeAfter = size
+
+	return result, nil
+}
+
+func directorySize(root string) (int64, error) {
+
+	var size int64
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
+
+// Formats a number of bytes for display, e.g. "12.3 KiB"
+func formatSize(size int64) string {
+
+	const unit = 1024
+	if size < unit && size > -unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB"}
+	i := -1
+	for (value >= unit || value <= -unit) && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
+
+func handleGCCommand(args []string) error {
+
+	pack, args := extractFlag(args, "--pack")
+	dryRun, args := extractFlag(args, "--dry-run", "-n")
+
+	if len(args) > 0 {
+		return &UsageError{Usage: GC_USAGE}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -config.GC.DeletedDays)
+	oplogCutoff := time.Now().AddDate(0, 0, -config.GC.OplogDays)
+	result, err := collectAthinaGarbage(cutoff, oplogCutoff, pack, dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "Dropped"
+	if dryRun {
+		verb = "Would drop"
+	}
+
+	for _, filename := range result.deletedFiles {
+		fmt.Printf("%s the history of \"%s\", deleted more than %d days ago\n", verb, filename, config.GC.DeletedDays)
+	}
+	if result.trashEntry.ID != "" {
+		fmt.Println("The dropped histories can be restored with 'athina trash restore " + result.trashEntry.ID + "'")
+	}
+	fmt.Printf("%s %d empty changes\n", verb, result.emptyChanges)
+	fmt.Printf("%s %d orphaned stash items\n", verb, result.stashItems)
+	fmt.Printf("%s %d operations recorded more than %d days ago, and %d unused blobs of the operation log\n", verb, result.operations, config.GC.OplogDays, result.blobs)
+
+	if dryRun {
+		return nil
+	}
+
+	if pack {
+		fmt.Printf("Packed %d objects into %s\n", result.packed, packPath()+packData)
+	}
+	fmt.Printf("Reclaimed %s (%s -> %s)\n", formatSize(result.sizeBefore-result.sizeAfter), formatSize(result.sizeBefore), formatSize(result.sizeAfter))
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const GREP_USAGE = "athina grep [-S] [pattern] [filename(s)]"
+
+// Searches every recorded version of the files for lines matching the pattern, and prints each match with the hash of the version it was found in
+func grepAthinaFiles(pattern *regexp.Regexp, filenames []string) error {
+
+	for _, filename := range filenames {
+
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return err
+		}
+
+		err = replayAthinaFile(athinafile, func(index int, filediff Filediff, before string, after string) error {
+
+			// Versions that did not change the content would only repeat the matches of the previous version
+			if index > 0 && before == after {
+				return nil
+			}
+
+			for number, line := range splitLines(after) {
+				line = strings.TrimRight(line, "\r\n")
+				if pattern.MatchString(line) {
+					fmt.Printf("%s %s:%d: %s\n", colorize(COLOR_YELLOW, shortenHash(filediff.Hash)), filename, number+1, line)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lists the Filediffs that changed the number of occurrences of needle, like git's pickaxe
+func pickaxeAthinaFiles(needle string, filenames []string) error {
+
+	for _, filename := range filenames {
+
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return err
+		}
+
+		err = replayAthinaFile(athinafile, func(index int, filediff Filediff, before string, after string) error {
+
+			countBefore := strings.Count(before, needle)
+			countAfter := strings.Count(after, needle)
+			if countBefore == countAfter {
+				return nil
+			}
+
+			fmt.Printf("%s %-16s %-8s %s (%d -> %d occurrences)\n",
+				colorize(COLOR_YELLOW, shortenHash(filediff.Hash)),
+				filediff.formatTime(),
+				filediff.Change.short(),
+				filename,
+				countBefore,
+				countAfter)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func handleGrepCommand(args []string) error {
+
+	pickaxe := false
+	if len(args) > 0 && args[0] == "-S" {
+		pickaxe = true
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return &UsageError{Usage: GREP_USAGE}
+	}
+
+	// Search every tracked file, unless specific files are given
+	filenames, err := resolveRepositoryPaths(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if len(filenames) == 0 {
+		filenames, err = AthinaListFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	if pickaxe {
+		return withPager(func() error {
+			return pickaxeAthinaFiles(args[0], filenames)
+		})
+	}
+
+	pattern, err := regexp.Compile(args[0])
+	if err != nil {
+		return &UsageError{Usage: GREP_USAGE + " (invalid pattern: " + err.Error() + ")"}
+	}
+
+	return withPager(func() error {
+		return grepAthinaFiles(pattern, filenames)
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Hooks are executables in .athina/hooks that are run around athina operations.
+// Every hook gets the affected filenames as arguments, and one "<hash> <filename>" line per file on stdin.
+// A pre-hook that exits with a non-zero status aborts the operation, a failing post-hook only logs a warning
+const (
+	HOOK_PRE_UPDATE  = "pre-update"
+	HOOK_POST_UPDATE = "post-update"
+	HOOK_PRE_REVERT  = "pre-revert"
+	HOOK_POST_REVERT = "post-revert"
+)
+
+// HookError is returned when a pre-hook exits with a non-zero status
+type HookError struct {
+	Hook string
+	Err  error
+}
+
+func (e *HookError) Error() string {
+	return e.Hook + " " + ErrHookFailed.Error() + ": " + e.Err.Error()
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+func (e *HookError) Is(target error) bool {
+	return target == ErrHookFailed
+}
+
+// A file as it is passed on to a hook
+type hookFile struct {
+	filename string
+	hash     string
+}
+
+// Builds the hook input for files from their latest recorded hash. Untracked files get an empty hash
+func hookFilesFromObjects(filenames []string) []hookFile {
+
+	var files []hookFile
+	for _, filename := range filenames {
+		hash := ""
+		if athinafile, err := loadAthinaFileObject(filename); err == nil {
+			hash = athinafile.getLatestHash()
+		}
+		files = append(files, hookFile{filename: filename, hash: hash})
+	}
+
+	return files
+}
+
+func runHook(name string, files []hookFile) error {
+
+	if !config.IsHookEnabled(name) {
+		slog.Debug("hook is disabled in the config", "hook", name)
+		return nil
+	}
+
+	path := ATHINA_HOOKS + name
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return &HookError{Hook: name, Err: err}
+	}
+
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		slog.Warn("skipping hook that is not executable", "hook", name, "path", path)
+		return nil
+	}
+
+	var args []string
+	var input strings.Builder
+	for _, file := range files {
+		args = append(args, file.filename)
+		input.WriteString(file.hash + " " + file.filename + "\n")
+	}
+
+	slog.Debug("running hook", "hook", name, "files", args)
+
+	cmd := exec.Command("./"+path, args...)
+	cmd.Stdin = strings.NewReader(input.String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "ATHINA_HOOK="+name, "ATHINA_REPOSITORY="+repositoryRoot)
+
+	err = cmd.Run()
+	if err != nil {
+		return &HookError{Hook: name, Err: err}
+	}
+
+	return nil
+}
+
+// Runs a post-hook, whose failure can no longer undo the operation and is therefore only logged
+func runPostHook(name string, files []hookFile) {
+
+	err := runHook(name, files)
+	if err != nil {
+		slog.Warn("post hook failed", "hook", name, "error", err)
+	}
+}
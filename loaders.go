@@ -2,41 +2,35 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io/fs"
 	"os"
 )
 
 func loadAthinaFileObject(identifier string) (AthinaFile, error) {
 
-	// In '.athina/objects', there should be a file with the name of the identifier
-	// If the file does not exist, return an error, otherwise we can load the file in as a File object
-	if _, err := os.Stat(".athina/objects/" + identifier); os.IsNotExist(err) {
-		return AthinaFile{}, err
+	// If the objects folder itself is missing, we are not inside of a repository
+	if _, err := os.Stat(objectsPath()); os.IsNotExist(err) {
+		return AthinaFile{}, ErrRepositoryMissing
 	}
 
-	// Load it as a Json object into a File struct
-	file, err := os.Open(".athina/objects/" + identifier)
+	// In '.athina/objects' or in the pack, there should be an object with the name of the identifier
+	// If there is none, return an error, otherwise we can load it in as a File object
+	content, err := readAthinaObject(identifier)
+	if errors.Is(err, fs.ErrNotExist) {
+		return AthinaFile{}, &FileError{Filename: identifier, Err: ErrNotTracked}
+	}
 	if err != nil {
-		fmt.Println(err)
-		return AthinaFile{}, err
+		return AthinaFile{}, &FileError{Filename: identifier, Err: err}
 	}
-	defer file.Close()
 
+	// Load it as a Json object into a File struct
 	var f AthinaFile
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&f)
+	err = json.Unmarshal(content, &f)
 	if err != nil {
-		fmt.Println(err)
-		return AthinaFile{}, err
+		return AthinaFile{}, &CorruptObjectError{Filename: identifier, Err: err}
 	}
 
 	return f, nil
 
 }
-
-func loadConfig() {
-	file, _ := os.Open(ATHINA_CONFIG)
-	defer file.Close()
-	decoder := json.NewDecoder(file)
-	_ = decoder.Decode(&config)
-}
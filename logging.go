@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Installs the default slog logger according to the global options.
+// The returned function closes the log file, if one was opened
+func setupLogging(options globalOptions) (func() error, error) {
+
+	// By default only warnings are shown, so that the output of commands stays clean
+	level := slog.LevelWarn
+	if options.verbose {
+		level = slog.LevelDebug
+	} else if options.quiet {
+		level = slog.LevelError
+	}
+
+	var writer io.Writer = os.Stderr
+	closer := func() error { return nil }
+
+	if options.logFile != "" {
+		file, err := os.OpenFile(options.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return closer, err
+		}
+		writer = file
+		closer = file.Close
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if options.logFormat == "json" {
+		handler = slog.NewJSONHandler(writer, handlerOptions)
+	} else {
+		handler = slog.NewTextHandler(writer, handlerOptions)
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	return closer, nil
+}
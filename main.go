@@ -3,9 +3,11 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
-
-	"github.com/sergi/go-diff/diffmatchpatch"
+	"path/filepath"
+	"slices"
 )
 
 var config Config
@@ -25,29 +27,96 @@ func isDeltaDiffEmpty(delta string) bool {
 
 func AthinaUpdateAllFiles(log bool) error {
 
+	// Collect every change up front, so that the pre-update hook gets to see (and veto) all of them at once
+	var changes []AthinaFileChange
+	var filenames []string
 	for change := range AthinaLookForFileChanges() {
+		if change.action == AthinaFileChangeActionError {
+			return change.err
+		}
+
+		changes = append(changes, change)
+		if change.filename != "" {
+			filenames = append(filenames, change.filename)
+		}
+	}
+
+	if len(filenames) > 0 {
+		err := runHook(HOOK_PRE_UPDATE, hookFilesFromObjects(filenames))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, change := range changes {
 		switch change.action {
 		case AthinaFileChangeActionAdd:
-			_ = AthinaAddFile(change.filename)
+			err := AthinaAddFile(change.filename)
+			if err != nil {
+				return err
+			}
 			if log {
 				fmt.Println("New file: " + change.filename)
 			}
 
 		case AthinaFileChangeActionDelete:
-			_ = AthinaDeleteFile(change.filename)
+			err := AthinaDeleteFile(change.filename)
+			if err != nil {
+				return err
+			}
 			if log {
 				fmt.Println("Deleted file: " + change.filename)
 			}
 
+		case AthinaFileChangeActionRename:
+			athinafile, err := loadAthinaFileObject(change.from)
+			if err != nil {
+				return err
+			}
+
+			// The file may have been changed while it was being renamed
+			delta, err := diffAthinaFileObjectAndFile(athinafile, change.filename)
+			if err != nil {
+				return err
+			}
+
+			err = AthinaRenameFile(change.from, change.filename, delta)
+			if err != nil {
+				return err
+			}
+			if log {
+				fmt.Println("Renamed file: " + change.from + " -> " + change.filename)
+			}
+
+		case AthinaFileChangeActionCopy:
+			athinafile, err := loadAthinaFileObject(change.from)
+			if err != nil {
+				return err
+			}
+
+			// The copy is recorded as diverging from the latest recorded content of its source
+			delta, err := diffAthinaFileObjectAndFile(athinafile, change.filename)
+			if err != nil {
+				return err
+			}
+
+			err = AthinaCopyFileHistory(change.from, change.filename, delta)
+			if err != nil {
+				return err
+			}
+			if log {
+				fmt.Println("Copied file: " + change.from + " -> " + change.filename)
+			}
+
 		case AthinaFileChangeActionModify:
-			_ = AthinaUpdateFile(change.filename)
+			err := AthinaUpdateFile(change.filename)
+			if err != nil {
+				return err
+			}
 			if log {
 				fmt.Println("Modified file: " + change.filename)
 			}
 
-		case AthinaFileChangeActionError:
-			fmt.Println("Error: " + change.err.Error())
-			return change.err
 		case AthinaFileChangeActionNone:
 			if log {
 				fmt.Println("No changes detected")
@@ -55,6 +124,11 @@ func AthinaUpdateAllFiles(log bool) error {
 
 		}
 	}
+
+	if len(filenames) > 0 {
+		runPostHook(HOOK_POST_UPDATE, hookFilesFromObjects(filenames))
+	}
+
 	return nil
 }
 
@@ -62,22 +136,17 @@ func AthinaUpdateFile(filename string) error {
 
 	// If the file does not exist but there exists a AthinaFile object for the file, we want to mark that the file has been deleted
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		if _, err := os.Stat(ATHINA_PATH_TO_OBJECTS + filename); !os.IsNotExist(err) {
-			err := AthinaDeleteFile(filename)
-			if err != nil {
-				fmt.Println(err)
-				return err
-			}
+		if !athinaObjectExists(filename) {
+			return &FileError{Filename: filename, Err: ErrNotTracked}
 		}
 
-		return nil
+		return AthinaDeleteFile(filename)
 	}
 
 	// If there is no AthinaFile object for this file, that means that this is a new file and we want to create one
-	if _, err := os.Stat(ATHINA_PATH_TO_OBJECTS + filename); os.IsNotExist(err) {
+	if !athinaObjectExists(filename) {
 		err := AthinaAddFile(filename)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 
@@ -87,13 +156,16 @@ func AthinaUpdateFile(filename string) error {
 	// Otherwise, if there exists a AthinaFile object for this file, and the file exists in the current directory, we want to compare the two
 	athinafile, err := loadAthinaFileObject(filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
+	// Unless the file was deleted before, in which case it has been added again
+	if athinafile.isDeleted() {
+		return AthinaAddFile(filename)
+	}
+
 	diffs, err := diffAthinaFileObjectAndFile(athinafile, filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
@@ -102,26 +174,43 @@ func AthinaUpdateFile(filename string) error {
 
 		filediff := newFilediff(newFileDiffOptions{deleted: false, change: AthinaFileChangeActionModify, delta: diffs})
 
-		AthinaModifyFile(athinafile, []Filediff{filediff})
+		return AthinaModifyFile(athinafile, []Filediff{filediff})
 
-	} else {
-		fmt.Println("No changes detected in file: " + filename)
-		return nil
 	}
 
-	return errors.New("file not found")
+	slog.Debug("no changes detected", "file", filename)
+	return nil
 }
 
 func AthinaAddFile(filename string) error {
 
+	// A file that was deleted and has now reappeared keeps its history, and is recorded as being added again
+	if athinaObjectExists(filename) {
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return err
+		}
+
+		if athinafile.isDeleted() {
+			delta, err := diffAthinaFileObjectAndFile(athinafile, filename)
+			if err != nil {
+				return err
+			}
+
+			slog.Debug("adding deleted file again", "file", filename)
+			filediff := newFilediff(newFileDiffOptions{added: true, change: AthinaFileChangeActionAdd, delta: delta})
+			return AthinaModifyFile(athinafile, []Filediff{filediff})
+		}
+	}
+
 	athinafile, err := createInitialAthinaFileObject(filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
-	athinafile.Save()
-	return nil
+	slog.Debug("adding file", "file", filename, "hash", athinafile.Diffs[0].Hash)
+
+	return athinafile.Save()
 }
 
 func AthinaDeleteFile(filename string) error {
@@ -129,35 +218,44 @@ func AthinaDeleteFile(filename string) error {
 	// Load the Athina object
 	athinafile, err := loadAthinaFileObject(filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
+	// There is no need to record the deletion twice
+	if athinafile.isDeleted() {
+		slog.Debug("file is already marked as deleted", "file", filename)
+		return nil
+	}
+
 	// Make a new Filediff object
 	filediff := newFilediff(newFileDiffOptions{deleted: true, change: AthinaFileChangeActionDelete})
+	slog.Debug("marking file as deleted", "file", filename, "hash", filediff.Hash)
 	athinafile.Diffs = append(athinafile.Diffs, filediff)
-	athinafile.Save()
 
-	return nil
+	return athinafile.Save()
 }
 
 func AthinaModifyFile(athinafile AthinaFile, diffs []Filediff) error {
 
 	// Append the new diffs to the Athina object
 	athinafile.Diffs = append(athinafile.Diffs, diffs...)
-	athinafile.Save()
-	return nil
+	for _, filediff := range diffs {
+		slog.Debug("recording change", "file", athinafile.Filename, "change", filediff.Change, "hash", filediff.Hash)
+	}
+
+	return athinafile.Save()
 }
 
 func AthinaDetectFileChange(filename string) (AthinaFileChange, error) {
 
 	// If the file does not exist in the .athina/objects folder
-	if _, err := os.Stat(ATHINA_PATH_TO_OBJECTS + filename); os.IsNotExist(err) {
+	if !athinaObjectExists(filename) {
 
 		// And the file does not exist in the current directory
 		if _, err := os.Stat(filename); os.IsNotExist(err) {
 
 			// Then there must be an error
+			err = &FileError{Filename: filename, Err: ErrNotTracked}
 			return AthinaFileChange{action: AthinaFileChangeActionError, err: err}, err
 		}
 
@@ -167,32 +265,38 @@ func AthinaDetectFileChange(filename string) (AthinaFileChange, error) {
 		// If the file does exist in the .athina/objects folder
 	} else {
 
+		// Load the Athina object
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return AthinaFileChange{action: AthinaFileChangeActionError, err: err}, err
+		}
+
 		// But it does not exist in the current directory
 		if _, err := os.Stat(filename); os.IsNotExist(err) {
 
-			// Then the file has been deleted
+			// Then the file has been deleted, unless that has already been recorded
+			if athinafile.isDeleted() {
+				return AthinaFileChange{action: AthinaFileChangeActionNone}, nil
+			}
 			return AthinaFileChange{action: AthinaFileChangeActionDelete, filename: filename}, nil
 
 			// Otherwise, if it does exist in the .athina/objects folder and it does exist in the current directory, we check it for any modifications since the last update
 		} else {
 
-			// Load the Athina object
-			athinafile, err := loadAthinaFileObject(filename)
-			if err != nil {
-				fmt.Println(err)
-				return AthinaFileChange{action: AthinaFileChangeActionError, err: err}, err
+			// A file that was deleted before and exists again has been added again
+			if athinafile.isDeleted() {
+				return AthinaFileChange{action: AthinaFileChangeActionAdd, filename: filename}, nil
 			}
 
 			// Open the file in the current directory
 			diff, err := diffAthinaFileObjectAndFile(athinafile, filename)
 			if err != nil {
-				fmt.Println(err)
 				return AthinaFileChange{action: AthinaFileChangeActionError, err: err}, err
 			}
 
 			// If there are changes, print out the changes
 			if !isDeltaDiffEmpty(diff) {
-				fmt.Println("Changes detected in file: " + filename)
+				slog.Debug("changes detected", "file", filename)
 				return AthinaFileChange{action: AthinaFileChangeActionModify, file: athinafile, filename: filename, diffs: athinafile.Diffs}, nil
 			} else {
 				return AthinaFileChange{action: AthinaFileChangeActionNone}, nil
@@ -213,64 +317,112 @@ func AthinaLookForFileChanges() <-chan AthinaFileChange {
 		// If there are no changes, do nothing
 
 		// Get all the files in the .athina/objects folder
-		files, err := os.ReadDir(ATHINA_PATH_TO_OBJECTS)
+		files, err := AthinaListFiles()
 		if err != nil {
-			fmt.Println(err)
 			ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+			close(ch)
+			return
 		}
 
+		// Deletions and new files are held back until the end, as pairs of them may turn out to be renames
+		var deleted, added []AthinaFileChange
+
 		// For each file in the .athina/objects folder, check if there is a corresponding file in the current directory
 		for _, file := range files {
 
-			if _, err := os.Stat(file.Name()); errors.Is(err, os.ErrNotExist) {
-				// File exists in the .athina/objects folder, but not in the current directory
-				// This means that the file has been deleted
-				ch <- AthinaFileChange{action: AthinaFileChangeActionDelete, filename: file.Name()}
+			slog.Debug("scanning tracked file", "file", file)
 
-			} else {
+			athinafile, err := loadAthinaFileObject(file)
+			if err != nil {
+				ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+				continue
+			}
 
-				athinafile, err := loadAthinaFileObject(file.Name())
-				if err != nil {
-					fmt.Println(err)
-					ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+			if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+				// File exists in the .athina/objects folder, but not in the current directory
+				// This means that the file has been deleted, unless we already know that
+				if !athinafile.isDeleted() {
+					deleted = append(deleted, AthinaFileChange{action: AthinaFileChangeActionDelete, filename: file})
 				}
 
-				diff, err := diffAthinaFileObjectAndFile(athinafile, file.Name())
+			} else if athinafile.isDeleted() {
+				// The file was deleted before, and has reappeared since
+				ch <- AthinaFileChange{action: AthinaFileChangeActionAdd, filename: file}
+
+			} else {
+
+				diff, err := diffAthinaFileObjectAndFile(athinafile, file)
 				if err != nil {
-					fmt.Println(err)
 					ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+					continue
 				}
 
 				if !isDeltaDiffEmpty(diff) {
-					ch <- AthinaFileChange{action: AthinaFileChangeActionModify, file: athinafile, filename: file.Name(), delta: diff}
+					ch <- AthinaFileChange{action: AthinaFileChangeActionModify, file: athinafile, filename: file, delta: diff}
 
 				}
 			}
 		}
 
-		// Now we want to fo through all the files in the current directry that are not in the .athina/objects folder
+		// Now we want to go through all the files in the working tree, subdirectories included, that are not in the .athina/objects folder
 		// If there are any, we want to add them to the .athina/objects folder
-		currentfiles, err := os.ReadDir(".")
-		if err != nil {
-			fmt.Println(err)
-			ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
-		}
-
-		for _, currentfile := range currentfiles {
+		err = filepath.WalkDir(".", func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
 
-			if config.IsIgnored(currentfile.Name()) {
-				continue
+			filename := filepath.ToSlash(path)
+			if filename == "." {
+				return nil
 			}
 
-			if currentfile.IsDir() {
-				continue
+			// Athina's own folder is never tracked, and an ignored directory hides everything in it
+			if filename == ATHINA_FOLDER || config.IsIgnored(filename) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
 			}
 
-			if _, err := os.Stat(ATHINA_PATH_TO_OBJECTS + currentfile.Name()); errors.Is(err, os.ErrNotExist) {
-				// File exists in the current directory, but not in the .athina/objects folder, this means that the file is new and should be added
-				ch <- AthinaFileChange{action: AthinaFileChangeActionAdd, filename: currentfile.Name()}
+			if entry.IsDir() {
+				return nil
+			}
 
+			if !athinaObjectExists(filename) {
+				// File exists in the working tree, but not in the .athina/objects folder, this means that the file is new and should be added
+				added = append(added, AthinaFileChange{action: AthinaFileChangeActionAdd, filename: filename})
 			}
+
+			return nil
+		})
+		if err != nil {
+			ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+			close(ch)
+			return
+		}
+
+		// Files that are gone from the working directory, whether they turn out to be renamed or deleted, can not be the source of a copy
+		var gone []string
+		for _, change := range deleted {
+			gone = append(gone, change.filename)
+		}
+
+		renames, deleted, added, err := detectRenames(deleted, added)
+		if err != nil {
+			ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+			close(ch)
+			return
+		}
+
+		copies, added, err := detectCopies(added, gone)
+		if err != nil {
+			ch <- AthinaFileChange{action: AthinaFileChangeActionError, err: err}
+			close(ch)
+			return
+		}
+
+		for _, change := range slices.Concat(renames, copies, deleted, added) {
+			ch <- change
 		}
 		close(ch)
 	}()
@@ -289,8 +441,10 @@ func createInitialAthinaFileObject(filename string) (AthinaFile, error) {
 	// read the files content
 	lf, err := os.Open(filename)
 	if err != nil {
-		fmt.Println(err)
-		return AthinaFile{}, err
+		if os.IsNotExist(err) {
+			err = ErrNotTracked
+		}
+		return AthinaFile{}, &FileError{Filename: filename, Err: err}
 	}
 
 	defer lf.Close()
@@ -298,15 +452,13 @@ func createInitialAthinaFileObject(filename string) (AthinaFile, error) {
 	// Read the content of file 'test.txt'
 	fileinfo, err := lf.Stat()
 	if err != nil {
-		fmt.Println(err)
 		return AthinaFile{}, err
 	}
 
 	filesize := fileinfo.Size()
 	filecontent := make([]byte, filesize)
 	_, err = lf.Read(filecontent)
-	if err != nil {
-		fmt.Println(err)
+	if err != nil && filesize > 0 {
 		return AthinaFile{}, err
 	}
 
@@ -319,38 +471,35 @@ func createInitialAthinaFileObject(filename string) (AthinaFile, error) {
 
 }
 
-// Removes the file from the .athina/objects folder
+// Removes the file from the .athina/objects folder, by moving its object into the trash
 func AthinaRemoveFile(filename string) error {
 
-	err := os.Remove(ATHINA_PATH_TO_OBJECTS + filename)
-	if err != nil {
-		fmt.Println(err)
-		return err
-
-	}
-
-	return nil
+	return trashAthinaObject(filename, "remove "+filename)
 
 }
 
-// Removes and re-initializes the .athina/object entry for said file
+// Removes and re-initializes the .athina/object entry for said file, the old history is moved into the trash
 func AthinaResetFile(filename string) error {
 
 	// Remove the file from the .athina/objects directory
-	err := os.Remove(ATHINA_PATH_TO_OBJECTS + filename)
+	err := trashAthinaObject(filename, "reset "+filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
 	// Re-initialize the file
-	err = AthinaAddFile(filename)
-	if err != nil {
-		fmt.Println(err)
-		return err
+	return AthinaAddFile(filename)
+
+}
+
+func trashAthinaObject(filename string, reason string) error {
+
+	if !athinaObjectExists(filename) {
+		return &FileError{Filename: filename, Err: ErrNotTracked}
 	}
 
-	return nil
+	_, err := moveToTrash(reason, []string{objectsPath() + filename})
+	return err
 
 }
 
@@ -359,11 +508,32 @@ func AthinaRevertFileByHash(filename string, hash string) error {
 	// Load the Athina object
 	athinafile, err := loadAthinaFileObject(filename)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
-	AthinaRevertFileObjectByHash(athinafile, hash)
+	// Reverting overwrites the working file, so we refuse to do it if that would throw away unrecorded changes
+	if _, err := os.Stat(filename); err == nil {
+		delta, err := diffAthinaFileObjectAndFile(athinafile, filename)
+		if err != nil {
+			return err
+		}
+
+		if !isDeltaDiffEmpty(delta) {
+			return &FileError{Filename: filename, Err: ErrDirtyWorkingFile}
+		}
+	}
+
+	err = runHook(HOOK_PRE_REVERT, []hookFile{{filename: filename, hash: hash}})
+	if err != nil {
+		return err
+	}
+
+	err = AthinaRevertFileObjectByHash(athinafile, hash)
+	if err != nil {
+		return err
+	}
+
+	runPostHook(HOOK_POST_REVERT, hookFilesFromObjects([]string{filename}))
 
 	return nil
 
@@ -371,79 +541,121 @@ func AthinaRevertFileByHash(filename string, hash string) error {
 
 func AthinaRevertFileObjectByHash(athinafile AthinaFile, hash string) error {
 
-	// First we check if there exists a filediff with this hash
-
-	var found bool = false
-	for _, filediff := range athinafile.Diffs {
-		if filediff.Hash == hash {
-			found = true
-			break
-
-		}
+	// Reconstruct the content of the file as it was at the given hash
+	origin, err := emulateDeltaDiffsUntilHash(athinafile, hash)
+	if err != nil {
+		return err
 	}
 
-	if !found {
-		return errors.New("no such hash found")
+	// Add a new diff to the Athinafile object, going from the current state to the reverted state
+	diff, err := diffAthinaFileObjectAndString(athinafile, origin)
+	if err != nil {
+		return err
 	}
 
-	origin := athinafile.Origin
-	dmp := diffmatchpatch.New()
+	filediff := newFilediff(newFileDiffOptions{deleted: false, change: AthinaFileChangeActionRevert, delta: diff})
 
-	// Handle the first diff, which is not a delta but rather just the origin
-	if athinafile.Diffs[0].Hash == hash {
-		origin = athinafile.Origin
-	} else {
+	err = AthinaModifyFile(athinafile, []Filediff{filediff})
+	if err != nil {
+		return err
+	}
 
-		for _, filediff := range athinafile.Diffs[1:] {
+	// Finally, write the reverted content to the file in the directory
+	slog.Debug("writing reverted content", "file", athinafile.Filename, "hash", hash)
+	err = touchFile(athinafile.Filename)
+	if err != nil {
+		return err
+	}
 
-			if isDeltaDiffEmpty(filediff.Delta) {
-				continue
-			}
+	err = os.WriteFile(athinafile.Filename, []byte(origin), 0644)
+	if err != nil {
+		return &FileError{Filename: athinafile.Filename, Err: err}
+	}
 
-			diff, _ := dmp.DiffFromDelta(origin, filediff.Delta)
-			origin = dmp.DiffText2(diff)
+	return nil
+}
 
-			if filediff.Hash == hash {
-				break
-			}
-		}
+func main() {
 
+	err := run(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		os.Exit(exitCodeForError(err))
 	}
 
-	// Add a new diff to the Athinafile object
-	diff, _ := diffAthinaFileObjectAndString(athinafile, origin)
-	athinafile.Origin = origin
-	filediff := newFilediff(newFileDiffOptions{deleted: false, change: AthinaFileChangeActionRevert, delta: diff})
+}
 
-	AthinaModifyFile(athinafile, []Filediff{filediff})
+func run(args []string) error {
 
-	//@TODO: Modify the file in the directory
+	// Parse the options that apply to every command, and set up logging accordingly
+	parsed, args, err := parseGlobalOptions(args)
+	if err != nil {
+		return err
+	}
+	options = parsed
 
-	err := os.WriteFile(athinafile.Filename, []byte(origin), 0644)
+	closeLog, err := setupLogging(options)
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
+	defer closeLog()
 
-	return nil
-}
+	// Only 'athina init' may create a repository, every other command has to find an existing one.
+	// The exception is 'athina config', which can manage the global config from anywhere
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
 
-func main() {
+	err = openAthinaRepository(command == "init")
+	inRepository := err == nil
+	if err != nil && !(errors.Is(err, ErrRepositoryMissing) && command == "config") {
+		return err
+	}
 
-	// Initialize the .athina folder
-	initializeAthinaFolder()
+	if inRepository {
+		// Make sure that the .athina folder is complete
+		err = initializeAthinaFolder()
+		if err != nil {
+			return err
+		}
 
-	// Load the config file
-	loadConfig()
+		// Find out which branch we are on, every object is read from and written to that branch
+		err = loadCurrentBranch()
+		if err != nil {
+			return err
+		}
+	}
 
-	// Load the stash file
-	loadStash()
+	// Load the global and the repository config files
+	err = loadConfig(inRepository)
+	if err != nil {
+		return err
+	}
 
-	args := os.Args[1:]
+	if inRepository {
+		// Load the stash file
+		_, err = loadStash()
+		if err != nil {
+			return &CorruptObjectError{Filename: ATHINA_STASH, Err: err}
+		}
+	}
 
 	// If no arguments are passed, we default to looking for file changes
 	if len(args) < 1 {
 
+		fmt.Println("On branch " + currentBranch)
+
+		pending, err := loadPendingMerge()
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			for _, filename := range pending.filenames() {
+				fmt.Println("Unresolved conflict: " + filename + " (merging \"" + pending.Branch + "\")")
+			}
+		}
+
 		for change := range AthinaLookForFileChanges() {
 
 			if config.IsIgnored(change.filename) {
@@ -456,16 +668,29 @@ func main() {
 				fmt.Println("Deleted file: " + change.filename)
 			case AthinaFileChangeActionModify:
 				fmt.Println("Modified file: " + change.filename)
+			case AthinaFileChangeActionRename:
+				fmt.Println("Renamed file: " + change.from + " -> " + change.filename)
+			case AthinaFileChangeActionCopy:
+				fmt.Println("Copied file: " + change.from + " -> " + change.filename)
 			case AthinaFileChangeActionError:
-				fmt.Println("Error: " + change.err.Error())
+				// Keep reporting the remaining files, but make sure the process exits with the error's code
+				fmt.Fprintln(os.Stderr, "Error: "+change.err.Error())
+				err = change.err
 			case AthinaFileChangeActionNone:
 				fmt.Println("No changes detected")
 			}
 		}
-		return
+		return err
+	}
+
+	// Commands that change the repository are recorded in the operation log, so that they can be undone
+	if slices.Contains(recordedCommands, command) {
+		return recordOperation(args, func() error {
+			return handleCLI(args)
+		})
 	}
 
 	// If arguments are passed, we send it to handleCLI
-	handleCLI(args)
+	return handleCLI(args)
 
 }
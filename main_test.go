@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLookForNestedFileChanges(t *testing.T) {
+
+	newTestRepository(t)
+
+	writeTestFile(t, "a.txt", "a\n")
+	writeTestFile(t, "sub/b.txt", "b\n")
+	runCommand(t, "update")
+
+	if !athinaObjectExists("sub/b.txt") {
+		t.Fatal("update did not pick up sub/b.txt")
+	}
+
+	writeTestFile(t, "sub/b.txt", "b\nb\n")
+	writeTestFile(t, "sub/deeper/new.txt", "new\n")
+	writeTestFile(t, "skipped/c.txt", "c\n")
+	runCommand(t, "ignore", "skipped")
+
+	changes := map[string]AthinaFileChangeAction{}
+	for change := range AthinaLookForFileChanges() {
+		if change.action == AthinaFileChangeActionError {
+			t.Fatal(change.err)
+		}
+		changes[change.filename] = change.action
+	}
+
+	want := map[string]AthinaFileChangeAction{
+		"sub/b.txt":          AthinaFileChangeActionModify,
+		"sub/deeper/new.txt": AthinaFileChangeActionAdd,
+	}
+	for filename, action := range want {
+		if changes[filename] != action {
+			t.Errorf("change of %s = %v, want %v", filename, changes[filename], action)
+		}
+	}
+	if _, ok := changes["skipped/c.txt"]; ok {
+		t.Error("a file in an ignored directory was reported as a change")
+	}
+	if len(changes) != len(want) {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+}
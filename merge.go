@@ -0,0 +1,607 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const MERGE_USAGE = "athina merge [branch] [filename(s)]"
+const RESOLVE_USAGE = "athina resolve [filename(s)] [--force]"
+
+// Merges that left conflicts behind are remembered per branch in this file, until every conflicted file has been resolved
+const ATHINA_MERGE = "merge.json"
+
+const (
+	CONFLICT_MARKER_OURS   = "<<<<<<<"
+	CONFLICT_MARKER_SPLIT  = "======="
+	CONFLICT_MARKER_THEIRS = ">>>>>>>"
+)
+
+// A merge that is waiting for its conflicts to be resolved
+type PendingMerge struct {
+	Branch string              `json:"branch"`
+	Files  map[string][]string `json:"files"`
+}
+
+func pendingMergePath() string {
+	return branchPath(currentBranch) + ATHINA_MERGE
+}
+
+func loadPendingMerge() (*PendingMerge, error) {
+
+	content, err := os.ReadFile(pendingMergePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pending PendingMerge
+	err = json.Unmarshal(content, &pending)
+	if err != nil {
+		return nil, &CorruptObjectError{Filename: pendingMergePath(), Err: err}
+	}
+
+	return &pending, nil
+}
+
+func (p *PendingMerge) filenames() []string {
+
+	var filenames []string
+	for filename := range p.Files {
+		filenames = append(filenames, filename)
+	}
+	slices.Sort(filenames)
+
+	return filenames
+}
+
+// Saves the pending merge, or removes it once nothing is left to resolve
+func (p *PendingMerge) Save() error {
+
+	if len(p.Files) == 0 {
+		return writeOptionalFile(pendingMergePath(), nil)
+	}
+
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(pendingMergePath(), append(content, '\n'), 0644)
+}
+
+// A change one side made to the common ancestor: the ancestor's lines from start up to end were replaced by lines
+type mergeChange struct {
+	start int
+	end   int
+	lines []string
+	ours  bool
+}
+
+// Lists the changes that turn the base into the other text, in order
+func mergeChanges(base string, other string, ours bool) []mergeChange {
+
+	var changes []mergeChange
+	i := 0
+	for _, diff := range diffLines(base, other) {
+		switch diff.Type {
+		case diffmatchpatch.DiffEqual:
+			i += len(diff.Lines)
+			continue
+		case diffmatchpatch.DiffDelete:
+			if n := len(changes); n > 0 && changes[n-1].end == i {
+				changes[n-1].end += len(diff.Lines)
+			} else {
+				changes = append(changes, mergeChange{start: i, end: i + len(diff.Lines), ours: ours})
+			}
+			i += len(diff.Lines)
+		case diffmatchpatch.DiffInsert:
+			if n := len(changes); n > 0 && changes[n-1].end == i {
+				changes[n-1].lines = append(changes[n-1].lines, diff.Lines...)
+			} else {
+				changes = append(changes, mergeChange{start: i, end: i, lines: diff.Lines, ours: ours})
+			}
+		}
+	}
+
+	return changes
+}
+
+// Applies the changes to the base's lines from start up to end
+func applyMergeChanges(base []string, start int, end int, changes []mergeChange) []string {
+
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, base[start:change.start]...)
+		lines = append(lines, change.lines...)
+		start = change.end
+	}
+
+	return append(lines, base[start:end]...)
+}
+
+// Merges the changes both sides made to the base line by line. Changes that overlap, or touch, are a conflict
+// unless both sides made the same change, and end up between conflict markers. Returns the merged text and the number of conflicts
+func mergeThreeWay(base string, ours string, theirs string, oursLabel string, theirsLabel string) (string, int) {
+
+	baseLines := splitLines(base)
+	changes := append(mergeChanges(base, ours, true), mergeChanges(base, theirs, false)...)
+	slices.SortStableFunc(changes, func(a, b mergeChange) int {
+		if a.start != b.start {
+			return a.start - b.start
+		}
+		return a.end - b.end
+	})
+
+	var merged strings.Builder
+	conflicts := 0
+	position := 0
+	for i := 0; i < len(changes); {
+
+		// Group every change that overlaps or touches the ones before it
+		start, end := changes[i].start, changes[i].end
+		j := i + 1
+		for j < len(changes) && changes[j].start <= end {
+			end = max(end, changes[j].end)
+			j++
+		}
+		group := changes[i:j]
+		i = j
+
+		var oursChanges, theirsChanges []mergeChange
+		for _, change := range group {
+			if change.ours {
+				oursChanges = append(oursChanges, change)
+			} else {
+				theirsChanges = append(theirsChanges, change)
+			}
+		}
+
+		merged.WriteString(strings.Join(baseLines[position:start], ""))
+		position = end
+
+		oursLines := applyMergeChanges(baseLines, start, end, oursChanges)
+		theirsLines := applyMergeChanges(baseLines, start, end, theirsChanges)
+
+		switch {
+		case len(theirsChanges) == 0 || slices.Equal(oursLines, theirsLines):
+			merged.WriteString(strings.Join(oursLines, ""))
+		case len(oursChanges) == 0:
+			merged.WriteString(strings.Join(theirsLines, ""))
+		default:
+			conflicts++
+			merged.WriteString(CONFLICT_MARKER_OURS + " " + oursLabel + "\n")
+			merged.WriteString(terminateLine(strings.Join(oursLines, "")))
+			merged.WriteString(CONFLICT_MARKER_SPLIT + "\n")
+			merged.WriteString(terminateLine(strings.Join(theirsLines, "")))
+			merged.WriteString(CONFLICT_MARKER_THEIRS + " " + theirsLabel + "\n")
+		}
+	}
+
+	merged.WriteString(strings.Join(baseLines[position:], ""))
+
+	return merged.String(), conflicts
+}
+
+// Makes sure that text which is followed by a conflict marker ends with a line break
+func terminateLine(text string) string {
+
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+
+	return text + "\n"
+}
+
+func hasConflictMarkers(text string) bool {
+
+	for _, line := range splitLines(text) {
+		if strings.HasPrefix(line, CONFLICT_MARKER_OURS+" ") || strings.HasPrefix(line, CONFLICT_MARKER_THEIRS+" ") || strings.TrimRight(line, "\r\n") == CONFLICT_MARKER_SPLIT {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether the history of ours already contains the version of theirs with the given hash, either directly or through a merge
+func containsVersion(ours AthinaFile, hash string) bool {
+
+	for _, filediff := range ours.Diffs {
+		if filediff.Hash == hash || slices.Contains(filediff.Parents, hash) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether the history of ours is the start of the history of theirs, so that ours can simply take theirs over
+func isPrefixHistory(ours AthinaFile, theirs AthinaFile) bool {
+
+	if len(ours.Diffs) > len(theirs.Diffs) {
+		return false
+	}
+
+	for i, filediff := range ours.Diffs {
+		if theirs.Diffs[i].Hash != filediff.Hash {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Finds the content of the last version that both histories agree on: the end of the history they share,
+// or a version that an earlier merge brought from one side into the other, whichever was recorded last.
+// Histories with nothing in common merge from an empty file
+func findMergeBase(ours AthinaFile, theirs AthinaFile) (string, error) {
+
+	side, index := ours, -1
+	var latest time.Time
+
+	consider := func(athinafile AthinaFile, i int) {
+		if index < 0 || !athinafile.Diffs[i].Time.Before(latest) {
+			side, index, latest = athinafile, i, athinafile.Diffs[i].Time
+		}
+	}
+
+	for i := 0; i < len(ours.Diffs) && i < len(theirs.Diffs) && ours.Diffs[i].Hash == theirs.Diffs[i].Hash; i++ {
+		consider(ours, i)
+	}
+
+	for _, pair := range [][2]AthinaFile{{ours, theirs}, {theirs, ours}} {
+		for _, filediff := range pair[0].Diffs {
+			for _, parent := range filediff.Parents {
+				for i, candidate := range pair[1].Diffs {
+					if candidate.Hash == parent {
+						consider(pair[1], i)
+					}
+				}
+			}
+		}
+	}
+
+	if index < 0 {
+		return "", nil
+	}
+
+	return emulateDeltaDiffsUntilIndex(side, index)
+}
+
+// The outcome of merging a single file
+const (
+	MERGE_UP_TO_DATE    = "up to date"
+	MERGE_FAST_FORWARD  = "fast-forwarded"
+	MERGE_ADDED         = "added"
+	MERGE_MERGED        = "merged"
+	MERGE_CONFLICT      = "conflict"
+	MERGE_DELETED       = "deleted"
+	MERGE_NOT_ON_BRANCH = "not on branch"
+)
+
+// Decides the merge of a file that at least one side deleted. A deletion on one side only wins when the other side left the file alone,
+// a deletion on one side and a change on the other is a conflict
+func mergeDeletion(oursDeleted bool, theirsDeleted bool, oursContent string, theirsContent string, baseContent string) string {
+
+	switch {
+	case oursDeleted && theirsDeleted:
+		return MERGE_UP_TO_DATE
+	case theirsDeleted && oursContent == baseContent:
+		return MERGE_DELETED
+	case oursDeleted && theirsContent == baseContent:
+		return MERGE_UP_TO_DATE
+	}
+
+	return MERGE_CONFLICT
+}
+
+// Merges the history of the file on another branch into the current branch, and writes the result to the working file.
+// Returns what happened, and the parents to record once a conflict has been resolved
+func mergeAthinaFile(filename string, branch string) (string, []string, error) {
+
+	var theirs AthinaFile
+	err := onBranch(branch, func() error {
+		var err error
+		theirs, err = loadAthinaFileObject(filename)
+		return err
+	})
+	if errors.Is(err, ErrNotTracked) {
+		return MERGE_NOT_ON_BRANCH, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = touchFile(filename)
+	if err != nil {
+		return "", nil, err
+	}
+
+	theirsContent, err := emulateDeltaDiffsFromAthinaFileObject(theirs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// A file that only exists on the other branch comes over with its full history
+	if !athinaObjectExists(filename) {
+		if theirs.isDeleted() {
+			return MERGE_UP_TO_DATE, nil, nil
+		}
+		if _, err := os.Stat(filename); err == nil {
+			return "", nil, &FileError{Filename: filename, Err: fmt.Errorf("%w, an untracked working file is in the way", ErrDirtyWorkingFile)}
+		}
+
+		err = theirs.Save()
+		if err != nil {
+			return "", nil, err
+		}
+		return MERGE_ADDED, nil, writeOptionalFile(filename, &theirsContent)
+	}
+
+	ours, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Merging overwrites the working file, so it must not hold anything that has not been recorded
+	if _, err := os.Stat(filename); err == nil && !ours.isDeleted() {
+		delta, err := diffAthinaFileObjectAndFile(ours, filename)
+		if err != nil {
+			return "", nil, err
+		}
+		if !isDeltaDiffEmpty(delta) {
+			return "", nil, &FileError{Filename: filename, Err: ErrDirtyWorkingFile}
+		}
+	}
+
+	if containsVersion(ours, theirs.getLatestHash()) {
+		return MERGE_UP_TO_DATE, nil, nil
+	}
+
+	// When ours has not changed since the histories split, it can simply take over theirs
+	if isPrefixHistory(ours, theirs) {
+		theirs.Filename = filename
+		err = theirs.Save()
+		if err != nil {
+			return "", nil, err
+		}
+
+		if theirs.isDeleted() {
+			return MERGE_FAST_FORWARD, nil, writeOptionalFile(filename, nil)
+		}
+		return MERGE_FAST_FORWARD, nil, writeOptionalFile(filename, &theirsContent)
+	}
+
+	oursContent, err := emulateDeltaDiffsFromAthinaFileObject(ours)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Both sides already ended up with the same content, e.g. because one of them merged the other
+	if oursContent == theirsContent && ours.isDeleted() == theirs.isDeleted() {
+		return MERGE_UP_TO_DATE, nil, nil
+	}
+
+	baseContent, err := findMergeBase(ours, theirs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	parents := []string{ours.getLatestHash(), theirs.getLatestHash()}
+
+	if ours.isDeleted() || theirs.isDeleted() {
+		switch mergeDeletion(ours.isDeleted(), theirs.isDeleted(), oursContent, theirsContent, baseContent) {
+		case MERGE_UP_TO_DATE:
+			return MERGE_UP_TO_DATE, nil, nil
+		case MERGE_DELETED:
+			filediff := newFilediff(newFileDiffOptions{deleted: true, change: AthinaFileChangeActionMerge, parents: parents})
+			err = AthinaModifyFile(ours, []Filediff{filediff})
+			if err != nil {
+				return "", nil, err
+			}
+			return MERGE_DELETED, nil, writeOptionalFile(filename, nil)
+		}
+
+		// Deleted on one side, changed on the other: leave the changed version in place to be resolved
+		content := oursContent
+		if ours.isDeleted() {
+			content = theirsContent
+		}
+		return MERGE_CONFLICT, parents, writeOptionalFile(filename, &content)
+	}
+
+	merged, conflicts := mergeThreeWay(baseContent, oursContent, theirsContent, currentBranch, branch)
+
+	err = writeOptionalFile(filename, &merged)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if conflicts > 0 {
+		slog.Debug("merge left conflicts", "file", filename, "conflicts", conflicts)
+		return MERGE_CONFLICT, parents, nil
+	}
+
+	err = recordMerge(ours, merged, parents)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return MERGE_MERGED, nil, nil
+}
+
+// Records a merge entry with both parents, going from the latest recorded content of ours to the merged content
+func recordMerge(ours AthinaFile, merged string, parents []string) error {
+
+	delta, err := diffAthinaFileObjectAndString(ours, merged)
+	if err != nil {
+		return err
+	}
+
+	filediff := newFilediff(newFileDiffOptions{change: AthinaFileChangeActionMerge, delta: delta, added: ours.isDeleted(), parents: parents})
+	return AthinaModifyFile(ours, []Filediff{filediff})
+}
+
+// Merges the other branch into the current one, every file the two branches have, or only the given files.
+// Returns the files that were left with conflicts
+func mergeBranch(branch string, filenames []string) ([]string, error) {
+
+	if !branchExists(branch) {
+		return nil, &FileError{Filename: branch, Err: ErrBranchNotFound}
+	}
+
+	if branch == currentBranch {
+		return nil, &UsageError{Usage: MERGE_USAGE + " (can not merge a branch into itself)"}
+	}
+
+	pending, err := loadPendingMerge()
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, fmt.Errorf("%w, resolve the conflicts of the merge of %q first", ErrMergeConflict, pending.Branch)
+	}
+
+	if len(filenames) == 0 {
+		filenames, err = AthinaListFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		err = onBranch(branch, func() error {
+			theirs, err := AthinaListFiles()
+			for _, filename := range theirs {
+				if !slices.Contains(filenames, filename) {
+					filenames = append(filenames, filename)
+				}
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(filenames)
+	}
+
+	pending = &PendingMerge{Branch: branch, Files: map[string][]string{}}
+	var conflicted []string
+	for _, filename := range filenames {
+		result, parents, err := mergeAthinaFile(filename, branch)
+		if err != nil {
+			return conflicted, errors.Join(err, pending.Save())
+		}
+
+		switch result {
+		case MERGE_UP_TO_DATE, MERGE_NOT_ON_BRANCH:
+			slog.Debug("nothing to merge", "file", filename, "result", result)
+		case MERGE_CONFLICT:
+			pending.Files[filename] = parents
+			conflicted = append(conflicted, filename)
+			fmt.Println(colorize(COLOR_RED, "Conflict") + " in \"" + filename + "\"")
+		default:
+			fmt.Println("File \"" + filename + "\" has been " + result)
+		}
+	}
+
+	return conflicted, pending.Save()
+}
+
+// Records the merge of conflicted files whose working file has been fixed up by hand
+func resolveAthinaFiles(filenames []string, force bool) error {
+
+	pending, err := loadPendingMerge()
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		return errors.New("there is no merge with conflicts to resolve")
+	}
+
+	for _, filename := range filenames {
+		parents, ok := pending.Files[filename]
+		if !ok {
+			return &FileError{Filename: filename, Err: errors.New("has no conflicts to resolve")}
+		}
+
+		content, err := readOptionalFile(filename)
+		if err != nil {
+			return err
+		}
+
+		if content != nil && hasConflictMarkers(*content) && !force {
+			return &FileError{Filename: filename, Err: fmt.Errorf("%w, the working file still has conflict markers, use --force to record it anyway", ErrMergeConflict)}
+		}
+
+		ours, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return err
+		}
+
+		// Resolving a conflict by removing the file records the deletion
+		if content == nil {
+			filediff := newFilediff(newFileDiffOptions{deleted: true, change: AthinaFileChangeActionMerge, parents: parents})
+			err = AthinaModifyFile(ours, []Filediff{filediff})
+		} else {
+			err = recordMerge(ours, *content, parents)
+		}
+		if err != nil {
+			return err
+		}
+
+		delete(pending.Files, filename)
+		fmt.Println("File \"" + filename + "\" has been resolved")
+	}
+
+	return pending.Save()
+}
+
+func handleMergeCommand(args []string) error {
+
+	if len(args) < 1 {
+		return &UsageError{Usage: MERGE_USAGE}
+	}
+
+	filenames, err := resolveRepositoryPaths(args[1:])
+	if err != nil {
+		return err
+	}
+
+	conflicted, err := mergeBranch(args[0], filenames)
+	if err != nil {
+		return err
+	}
+
+	if len(conflicted) > 0 {
+		return fmt.Errorf("%w in %d file(s), fix them and run 'athina resolve'", ErrMergeConflict, len(conflicted))
+	}
+
+	fmt.Println("Branch \"" + args[0] + "\" has been merged into \"" + currentBranch + "\"")
+
+	return nil
+}
+
+func handleResolveCommand(args []string) error {
+
+	force, args := extractFlag(args, "--force", "-f")
+	if len(args) < 1 {
+		return &UsageError{Usage: RESOLVE_USAGE}
+	}
+
+	filenames, err := resolveRepositoryPaths(args)
+	if err != nil {
+		return err
+	}
+
+	return resolveAthinaFiles(filenames, force)
+}
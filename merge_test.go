@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeThreeWay(t *testing.T) {
+
+	const base = "a\nb\nc\nd\n"
+
+	conflict := func(ours string, theirs string) string {
+		return CONFLICT_MARKER_OURS + " ours\n" + ours + CONFLICT_MARKER_SPLIT + "\n" + theirs + CONFLICT_MARKER_THEIRS + " theirs\n"
+	}
+
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		merged    string
+		conflicts int
+	}{
+		{"unchanged", base, base, base, 0},
+		{"only ours changed", "a\nB\nc\nd\n", base, "a\nB\nc\nd\n", 0},
+		{"only theirs changed", base, "a\nb\nc\nD\n", "a\nb\nc\nD\n", 0},
+		{"changes apart", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", 0},
+		{"same change on both sides", "a\nB\nc\nd\n", "a\nB\nc\nd\n", "a\nB\nc\nd\n", 0},
+		{"different changes to the same line", "a\nB1\nc\nd\n", "a\nB2\nc\nd\n", "a\n" + conflict("B1\n", "B2\n") + "c\nd\n", 1},
+		{"changes to adjacent lines", "a\nB\nc\nd\n", "a\nb\nC\nd\n", "a\n" + conflict("B\nc\n", "b\nC\n") + "d\n", 1},
+		{"inserts at the same line", "a\nx\nb\nc\nd\n", "a\ny\nb\nc\nd\n", "a\n" + conflict("x\n", "y\n") + "b\nc\nd\n", 1},
+		{"insert and delete at the same line", "a\nx\nb\nc\nd\n", "a\nc\nd\n", "a\n" + conflict("x\nb\n", "") + "c\nd\n", 1},
+		{"delete and change of the same line", "a\nc\nd\n", "a\nB\nc\nd\n", "a\n" + conflict("", "B\n") + "c\nd\n", 1},
+		{"same deletion on both sides", "a\nc\nd\n", "a\nc\nd\n", "a\nc\nd\n", 0},
+		{"deletions apart", "b\nc\nd\n", "a\nb\nc\n", "b\nc\n", 0},
+		{"two conflicts", "A1\nb\nc\nD1\n", "A2\nb\nc\nD2\n", conflict("A1\n", "A2\n") + "b\nc\n" + conflict("D1\n", "D2\n"), 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged, conflicts := mergeThreeWay(base, test.ours, test.theirs, "ours", "theirs")
+			if merged != test.merged || conflicts != test.conflicts {
+				t.Errorf("mergeThreeWay() = %q, %d conflicts, want %q, %d conflicts", merged, conflicts, test.merged, test.conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeDeletion(t *testing.T) {
+
+	const base = "base\n"
+	const changed = "changed\n"
+
+	tests := []struct {
+		name          string
+		oursDeleted   bool
+		theirsDeleted bool
+		ours          string
+		theirs        string
+		outcome       string
+	}{
+		{"deleted on both sides", true, true, base, base, MERGE_UP_TO_DATE},
+		{"deleted on both sides after changes", true, true, changed, changed, MERGE_UP_TO_DATE},
+		{"theirs deleted, ours unchanged", false, true, base, base, MERGE_DELETED},
+		{"theirs deleted, ours changed", false, true, changed, base, MERGE_CONFLICT},
+		{"ours deleted, theirs unchanged", true, false, base, base, MERGE_UP_TO_DATE},
+		{"ours deleted, theirs changed", true, false, base, changed, MERGE_CONFLICT},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outcome := mergeDeletion(test.oursDeleted, test.theirsDeleted, test.ours, test.theirs, base)
+			if outcome != test.outcome {
+				t.Errorf("mergeDeletion() = %q, want %q", outcome, test.outcome)
+			}
+		})
+	}
+}
+
+// Builds the history of a file from its contents, one version per content, recorded a minute apart
+func historyOf(start time.Time, contents ...string) AthinaFile {
+
+	dmp := newDiffMatchPatch()
+	athinafile := AthinaFile{Filename: "file.txt", Origin: contents[0]}
+	for i, content := range contents {
+		filediff := Filediff{Hash: sha1Hash(content), Time: start.Add(time.Duration(i) * time.Minute)}
+		if i > 0 {
+			filediff.Delta = dmp.DiffToDelta(dmp.DiffMain(contents[i-1], content, false))
+		}
+		athinafile.Diffs = append(athinafile.Diffs, filediff)
+	}
+
+	return athinafile
+}
+
+func TestFindMergeBase(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Theirs merged ours' second version later on
+	merged := historyOf(start, "v1\n", "v2\n", "theirs\n", "ours 2\n")
+	merged.Diffs[3].Parents = []string{sha1Hash("theirs\n"), sha1Hash("ours 2\n")}
+
+	tests := []struct {
+		name   string
+		ours   AthinaFile
+		theirs AthinaFile
+		base   string
+	}{
+		{"same history", historyOf(start, "v1\n", "v2\n"), historyOf(start, "v1\n", "v2\n"), "v2\n"},
+		{"split after the first version", historyOf(start, "v1\n", "ours\n"), historyOf(start, "v1\n", "theirs\n"), "v1\n"},
+		{"split after the second version", historyOf(start, "v1\n", "v2\n", "ours\n"), historyOf(start, "v1\n", "v2\n", "theirs\n"), "v2\n"},
+		{"nothing in common", historyOf(start, "ours\n"), historyOf(start, "theirs\n"), ""},
+		{"earlier merge", historyOf(start, "v1\n", "v2\n", "ours 1\n", "ours 2\n", "ours 3\n"), merged, "ours 2\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base, err := findMergeBase(test.ours, test.theirs)
+			if err != nil {
+				t.Fatalf("findMergeBase() failed: %v", err)
+			}
+			if base != test.base {
+				t.Errorf("findMergeBase() = %q, want %q", base, test.base)
+			}
+		})
+	}
+}
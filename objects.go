@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Objects are stored either loose, as one file per tracked file under .athina/objects,
+// or packed by 'athina gc --pack' into a single pack file with an index of where every object starts.
+// An object is never both: writing or removing an object first takes it out of the pack.
+// Every pack gets a name of its own, and the index names the pack it belongs to, so that a new pack never overwrites
+// the one the current index points into. Packs written before packs were named are called objects.pack
+const ATHINA_PACK_FOLDER = "pack/"
+const ATHINA_PACK_DATA = "objects.pack"
+const ATHINA_PACK_INDEX = "index.json"
+
+// Every branch has an objects folder and a pack of its own, see branchPath
+func objectsPath() string {
+	return branchPath(currentBranch) + "objects/"
+}
+
+func packPath() string {
+	return branchPath(currentBranch) + ATHINA_PACK_FOLDER
+}
+
+type packEntry struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// The index as it is stored in index.json
+type packIndexFile struct {
+	Pack    string               `json:"pack"`
+	Objects map[string]packEntry `json:"objects"`
+}
+
+// The loaded pack index and the name of the pack it points into, nil until it is first needed
+var packIndex map[string]packEntry
+var packData string
+
+func loadPackIndex() (map[string]packEntry, error) {
+
+	if packIndex != nil {
+		return packIndex, nil
+	}
+
+	index := packIndexFile{Pack: ATHINA_PACK_DATA, Objects: map[string]packEntry{}}
+	content, err := os.ReadFile(packPath() + ATHINA_PACK_INDEX)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err == nil {
+		err = json.Unmarshal(content, &index)
+
+		// Indexes written before packs were named are a plain map of objects into objects.pack
+		if err != nil || index.Objects == nil {
+			index = packIndexFile{Pack: ATHINA_PACK_DATA, Objects: map[string]packEntry{}}
+			err = json.Unmarshal(content, &index.Objects)
+		}
+		if err != nil {
+			return nil, &CorruptObjectError{Filename: packPath() + ATHINA_PACK_INDEX, Err: err}
+		}
+	}
+
+	packIndex, packData = index.Objects, index.Pack
+	return packIndex, nil
+}
+
+// Writes the index of the objects in the named pack. The index is swapped in whole, so it always matches one pack
+func savePackIndex(pack string, index map[string]packEntry) error {
+
+	content, err := json.Marshal(packIndexFile{Pack: pack, Objects: index})
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomically(packPath()+ATHINA_PACK_INDEX, content)
+	if err != nil {
+		return err
+	}
+
+	packIndex, packData = index, pack
+	return nil
+}
+
+func readPackedObject(filename string) ([]byte, error) {
+
+	index, err := loadPackIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := index[filename]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	file, err := os.Open(packPath() + packData)
+	if err != nil {
+		return nil, &CorruptObjectError{Filename: packPath() + packData, Err: err}
+	}
+	defer file.Close()
+
+	content := make([]byte, entry.Length)
+	_, err = file.ReadAt(content, entry.Offset)
+	if err != nil {
+		return nil, &CorruptObjectError{Filename: filename, Err: err}
+	}
+
+	return content, nil
+}
+
+// Reads the stored object of a file, wherever it lives. Returns an error matching fs.ErrNotExist if the file is not tracked
+func readAthinaObject(filename string) ([]byte, error) {
+
+	content, err := os.ReadFile(objectsPath() + filename)
+	if !os.IsNotExist(err) {
+		return content, err
+	}
+
+	return readPackedObject(filename)
+}
+
+// Like readAthinaObject, but returns nil for files that are not tracked
+func readOptionalAthinaObject(filename string) (*string, error) {
+
+	content, err := readAthinaObject(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(content)
+	return &s, nil
+}
+
+func athinaObjectExists(filename string) bool {
+
+	if _, err := os.Stat(objectsPath() + filename); err == nil {
+		return true
+	}
+
+	index, err := loadPackIndex()
+	if err != nil {
+		return false
+	}
+
+	_, ok := index[filename]
+	return ok
+}
+
+// Takes an object out of the pack index, before its loose object is written or removed.
+// Its data stays in the pack file until the next 'athina gc --pack'
+func forgetPackedObject(filename string) error {
+
+	index, err := loadPackIndex()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := index[filename]; !ok {
+		return nil
+	}
+
+	delete(index, filename)
+	return savePackIndex(packData, index)
+}
+
+// Turns a packed object back into a loose one, so that it can be moved around like any other file
+func unpackAthinaObject(filename string) error {
+
+	if _, err := os.Stat(objectsPath() + filename); err == nil {
+		return nil
+	}
+
+	content, err := readPackedObject(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("unpacking object", "file", filename)
+
+	err = os.MkdirAll(filepath.Dir(objectsPath()+filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(objectsPath()+filename, content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return forgetPackedObject(filename)
+}
+
+// Unpacks every object among the paths, which are relative to the repository root
+func unpackAthinaObjectPaths(paths []string) error {
+
+	for _, path := range paths {
+		filename, ok := strings.CutPrefix(filepath.ToSlash(path), objectsPath())
+		if !ok {
+			continue
+		}
+
+		err := unpackAthinaObject(filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes the object of a file as a loose object, or removes it when content is nil
+func writeOptionalAthinaObject(filename string, content *string) error {
+
+	err := touchFile(filename)
+	if err != nil {
+		return err
+	}
+
+	err = forgetPackedObject(filename)
+	if err != nil {
+		return err
+	}
+
+	return writeOptionalFile(objectsPath()+filename, content)
+}
+
+// Lists the filenames of every loose object
+func listLooseAthinaObjects() ([]string, error) {
+
+	var filenames []string
+	err := filepath.WalkDir(objectsPath(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		filename, err := filepath.Rel(objectsPath(), path)
+		if err != nil {
+			return err
+		}
+
+		filenames = append(filenames, filepath.ToSlash(filename))
+		return nil
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrRepositoryMissing
+	}
+
+	return filenames, err
+}
+
+// Moves every object, loose or already packed, into a freshly written pack, and removes the loose objects.
+// Returns how many objects the pack holds
+func packAthinaObjects() (int, error) {
+
+	loose, err := listLooseAthinaObjects()
+	if err != nil {
+		return 0, err
+	}
+
+	index, err := loadPackIndex()
+	if err != nil {
+		return 0, err
+	}
+
+	filenames := slices.Clone(loose)
+	for filename := range index {
+		if !slices.Contains(filenames, filename) {
+			filenames = append(filenames, filename)
+		}
+	}
+	slices.Sort(filenames)
+
+	var data []byte
+	packed := map[string]packEntry{}
+	for _, filename := range filenames {
+		content, err := readAthinaObject(filename)
+		if err != nil {
+			return 0, err
+		}
+
+		packed[filename] = packEntry{Offset: int64(len(data)), Length: int64(len(content))}
+		data = append(data, content...)
+	}
+
+	// The new pack is written next to the old one under a name of its own, and only then the index is switched over to it.
+	// A crash in between leaves the old index pointing into the old pack, which is still there
+	previous := packData
+	pack := "objects-" + sha1Hash(string(data)) + ".pack"
+	err = writeFileAtomically(packPath()+pack, data)
+	if err != nil {
+		return 0, err
+	}
+
+	err = savePackIndex(pack, packed)
+	if err != nil {
+		return 0, err
+	}
+
+	if previous != pack {
+		err = os.Remove(packPath() + previous)
+		if err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+
+	// Only now that every object is safely in the pack can the loose ones go
+	for _, filename := range loose {
+		err := os.Remove(objectsPath() + filename)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(packed), removeEmptyDirectories(objectsPath())
+}
+
+// Removes the empty directories below root, but not root itself
+func removeEmptyDirectories(root string) error {
+
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && filepath.Clean(path) != filepath.Clean(root) {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deepest directories first, so that their parents may become empty in turn
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			err = os.Remove(dirs[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,749 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Every command that changes the repository is recorded in an append-only journal, .athina/oplog.jsonl.
+// An operation holds the objects and working files it touched, and the files in .athina it rewrote such as tags and trees,
+// as they were before and after the command ran, which is all that 'athina undo' needs to roll it back
+type Operation struct {
+	ID      int             `json:"id"`
+	Time    time.Time       `json:"time"`
+	Command []string        `json:"command"`
+	Files   []OperationFile `json:"files"`
+	Paths   []OperationPath `json:"paths,omitempty"`
+	Undoes  []int           `json:"undoes,omitempty"`
+	Branch  string          `json:"branch,omitempty"`
+
+	// The branch that a switch left, empty for every other operation
+	Switched string `json:"switched,omitempty"`
+}
+
+// The state of a single file around an operation. Contents are stored as blobs (see storeBlob) and referenced by their hash,
+// an empty hash means that the object or working file did not exist. Working files are only recorded when the operation wrote to them.
+// Operations recorded before blobs existed hold the contents themselves, in the fields without a hash
+type OperationFile struct {
+	Filename          string  `json:"filename"`
+	ObjectBeforeHash  string  `json:"object_before_hash,omitempty"`
+	ObjectAfterHash   string  `json:"object_after_hash,omitempty"`
+	Working           bool    `json:"working,omitempty"`
+	WorkingBeforeHash string  `json:"working_before_hash,omitempty"`
+	WorkingAfterHash  string  `json:"working_after_hash,omitempty"`
+	ObjectBefore      *string `json:"object_before,omitempty"`
+	ObjectAfter       *string `json:"object_after,omitempty"`
+	WorkingBefore     *string `json:"working_before,omitempty"`
+	WorkingAfter      *string `json:"working_after,omitempty"`
+}
+
+// The state of a file in .athina around an operation, as the hashes of blobs. An empty hash means that the file did not exist
+type OperationPath struct {
+	Path       string `json:"path"`
+	BeforeHash string `json:"before_hash,omitempty"`
+	AfterHash  string `json:"after_hash,omitempty"`
+}
+
+// Content recorded in the operation log, either the hash of a blob or the content itself
+type operationContent struct {
+	hash    string
+	content *string
+}
+
+func (f OperationFile) objectBefore() operationContent {
+	return operationContent{hash: f.ObjectBeforeHash, content: f.ObjectBefore}
+}
+
+func (f OperationFile) objectAfter() operationContent {
+	return operationContent{hash: f.ObjectAfterHash, content: f.ObjectAfter}
+}
+
+func (f OperationFile) workingBefore() operationContent {
+	return operationContent{hash: f.WorkingBeforeHash, content: f.WorkingBefore}
+}
+
+func (f OperationFile) workingAfter() operationContent {
+	return operationContent{hash: f.WorkingAfterHash, content: f.WorkingAfter}
+}
+
+// Identifies the content without reading its blob. Empty when the file did not exist
+func (c operationContent) identity() string {
+
+	if c.hash != "" || c.content == nil {
+		return c.hash
+	}
+
+	return blobHash(*c.content)
+}
+
+func (c operationContent) load() (*string, error) {
+
+	if c.hash == "" {
+		return c.content, nil
+	}
+
+	return loadBlob(c.hash)
+}
+
+// Operations recorded before branches existed all ran on the default branch
+func (o Operation) branch() string {
+
+	if o.Branch == "" {
+		return DEFAULT_BRANCH
+	}
+
+	return o.Branch
+}
+
+const DEFAULT_OPLOG_DEPTH int = 20
+
+// Commands whose effects are recorded in the operation log
+var recordedCommands = []string{"update", "mv", "cp", "remove", "reset", "revert", "restore", "squash", "prune", "merge", "resolve", "pick", "checkout", "trash"}
+
+type fileSnapshot struct {
+	object  *string
+	working *string
+}
+
+// The files touched by the operation that is being recorded, as they were before it touched them. Nil when no operation is being recorded
+var journal map[string]fileSnapshot
+
+// The files in .athina, other than objects, that the operation that is being recorded rewrote, as they were before
+var journalPaths map[string]*string
+
+// Remembers the object and working file of the file before the operation that is being recorded changes them.
+// Everything that writes an object or a working file during a recorded command calls this first,
+// so that only the files a command touches are read and stored
+func touchFile(filename string) error {
+
+	if journal == nil {
+		return nil
+	}
+
+	if _, ok := journal[filename]; ok {
+		return nil
+	}
+
+	object, err := readOptionalAthinaObject(filename)
+	if err != nil {
+		return err
+	}
+
+	working, err := readOptionalFile(filename)
+	if err != nil {
+		return &FileError{Filename: filename, Err: err}
+	}
+
+	journal[filename] = fileSnapshot{object: object, working: working}
+	return nil
+}
+
+// Touches the files behind paths that are moved into or out of the trash. Paths that contain every object of the current branch touch every tracked file
+func touchPaths(paths []string) error {
+
+	if journal == nil {
+		return nil
+	}
+
+	for _, path := range paths {
+		path = filepath.ToSlash(path)
+
+		switch {
+		case strings.HasPrefix(objectsPath(), path+"/") || strings.HasPrefix(path+"/", packPath()):
+			filenames, err := AthinaListFiles()
+			if err != nil {
+				return err
+			}
+
+			for _, filename := range filenames {
+				err = touchFile(filename)
+				if err != nil {
+					return err
+				}
+			}
+
+		case strings.HasPrefix(path, objectsPath()):
+			err := touchFile(strings.TrimPrefix(path, objectsPath()))
+			if err != nil {
+				return err
+			}
+
+		case path != ATHINA_FOLDER && !strings.HasPrefix(path, ATHINA_FOLDER+"/"):
+			err := touchFile(path)
+			if err != nil {
+				return err
+			}
+
+		default:
+			err := touchMetadataPath(path)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Remembers a file in .athina, such as a tag or the trees of a branch, before the operation that is being recorded rewrites it.
+// Directories are left alone, the files in them that matter are touched one by one
+func touchMetadataPath(path string) error {
+
+	if _, ok := journalPaths[path]; ok {
+		return nil
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return nil
+	}
+
+	content, err := readOptionalFile(path)
+	if err != nil {
+		return err
+	}
+
+	journalPaths[path] = content
+	return nil
+}
+
+func blobHash(content string) string {
+	return sha1Hash(content)
+}
+
+// Stores the content under .athina/blobs, named by its hash, and returns the hash. The same content is only ever stored once.
+// A nil content is not stored, and gives an empty hash
+func storeBlob(content *string) (string, error) {
+
+	if content == nil {
+		return "", nil
+	}
+
+	hash := blobHash(*content)
+	path := ATHINA_BLOBS + hash
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	err := os.MkdirAll(ATHINA_BLOBS, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	return hash, writeFileAtomically(path, []byte(*content))
+}
+
+func loadBlob(hash string) (*string, error) {
+
+	content, err := readOptionalFile(ATHINA_BLOBS + hash)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, &CorruptObjectError{Filename: ATHINA_BLOBS + hash, Err: errors.New("the blob is missing")}
+	}
+
+	return content, nil
+}
+
+// Stores the states of the file around an operation as blobs
+func newOperationFile(filename string, before fileSnapshot, after fileSnapshot, working bool) (OperationFile, error) {
+
+	file := OperationFile{Filename: filename, Working: working}
+
+	var err error
+	file.ObjectBeforeHash, err = storeBlob(before.object)
+	if err != nil {
+		return file, err
+	}
+
+	file.ObjectAfterHash, err = storeBlob(after.object)
+	if err != nil {
+		return file, err
+	}
+
+	if !working {
+		return file, nil
+	}
+
+	file.WorkingBeforeHash, err = storeBlob(before.working)
+	if err != nil {
+		return file, err
+	}
+
+	file.WorkingAfterHash, err = storeBlob(after.working)
+	return file, err
+}
+
+func readOptionalFile(path string) (*string, error) {
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := string(content)
+	return &s, nil
+}
+
+func writeOptionalFile(path string, content *string) error {
+
+	if content == nil {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(*content), 0644)
+}
+
+func equalOptionalContent(a *string, b *string) bool {
+
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// Runs fn, and records whatever it changed as a new operation in the operation log
+func recordOperation(command []string, fn func() error) error {
+
+	journal = map[string]fileSnapshot{}
+	journalPaths = map[string]*string{}
+	fnErr := fn()
+	touched, touchedPaths := journal, journalPaths
+	journal, journalPaths = nil, nil
+
+	var files []OperationFile
+	objectsChanged := false
+	for filename, before := range touched {
+
+		object, err := readOptionalAthinaObject(filename)
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+
+		working, err := readOptionalFile(filename)
+		if err != nil {
+			return errors.Join(fnErr, &FileError{Filename: filename, Err: err})
+		}
+
+		objectChanged := !equalOptionalContent(before.object, object)
+		workingChanged := !equalOptionalContent(before.working, working)
+		if !objectChanged && !workingChanged {
+			continue
+		}
+
+		file, err := newOperationFile(filename, before, fileSnapshot{object: object, working: working}, workingChanged)
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+
+		files = append(files, file)
+		objectsChanged = objectsChanged || objectChanged
+	}
+
+	// Every state of the files that a command leaves behind is a snapshot, see Tree
+	if objectsChanged {
+		_, _, err := recordTree()
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+	}
+
+	// The trees are compared only now, after the tree of this operation has been appended
+	var paths []OperationPath
+	for path, before := range touchedPaths {
+		after, err := readOptionalFile(path)
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+
+		if equalOptionalContent(before, after) {
+			continue
+		}
+
+		operationPath := OperationPath{Path: path}
+		operationPath.BeforeHash, err = storeBlob(before)
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+		operationPath.AfterHash, err = storeBlob(after)
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+		paths = append(paths, operationPath)
+	}
+
+	// Even a failed command is recorded, as long as it changed something
+	if len(files) > 0 || len(paths) > 0 {
+		slices.SortFunc(files, func(x, y OperationFile) int {
+			return strings.Compare(x.Filename, y.Filename)
+		})
+		slices.SortFunc(paths, func(x, y OperationPath) int {
+			return strings.Compare(x.Path, y.Path)
+		})
+
+		_, err := appendOperation(Operation{Command: command, Files: files, Paths: paths, Branch: currentBranch})
+		if err != nil {
+			return errors.Join(fnErr, err)
+		}
+	}
+
+	return fnErr
+}
+
+func loadOperationLog() ([]Operation, error) {
+
+	file, err := os.Open(ATHINA_OPLOG)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var operations []Operation
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var operation Operation
+		err := json.Unmarshal(scanner.Bytes(), &operation)
+		if err != nil {
+			return nil, &CorruptObjectError{Filename: ATHINA_OPLOG, Err: err}
+		}
+		operations = append(operations, operation)
+	}
+
+	return operations, scanner.Err()
+}
+
+// Drops the operations recorded before the cutoff, which can then no longer be undone, and the blobs that no remaining operation refers to.
+// Returns how many operations and blobs were dropped, with dryRun how many would be
+func trimOperationLog(cutoff time.Time, dryRun bool) (int, int, error) {
+
+	operations, err := loadOperationLog()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var kept []Operation
+	referenced := map[string]bool{}
+	for _, operation := range operations {
+		if operation.Time.Before(cutoff) {
+			continue
+		}
+
+		kept = append(kept, operation)
+		for _, file := range operation.Files {
+			for _, hash := range []string{file.ObjectBeforeHash, file.ObjectAfterHash, file.WorkingBeforeHash, file.WorkingAfterHash} {
+				referenced[hash] = true
+			}
+		}
+		for _, path := range operation.Paths {
+			referenced[path.BeforeHash] = true
+			referenced[path.AfterHash] = true
+		}
+	}
+
+	entries, err := os.ReadDir(ATHINA_BLOBS)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+
+	var unreferenced []string
+	for _, entry := range entries {
+		if !referenced[entry.Name()] {
+			unreferenced = append(unreferenced, entry.Name())
+		}
+	}
+
+	dropped := len(operations) - len(kept)
+	if dryRun {
+		return dropped, len(unreferenced), nil
+	}
+
+	if dropped > 0 {
+		var content []byte
+		for _, operation := range kept {
+			line, err := json.Marshal(operation)
+			if err != nil {
+				return 0, 0, err
+			}
+			content = append(content, append(line, '\n')...)
+		}
+
+		err = writeFileAtomically(ATHINA_OPLOG, content)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// The blobs go only once the log no longer refers to them
+	for _, hash := range unreferenced {
+		err = os.Remove(ATHINA_BLOBS + hash)
+		if err != nil {
+			return dropped, 0, err
+		}
+	}
+
+	return dropped, len(unreferenced), nil
+}
+
+func appendOperation(operation Operation) (Operation, error) {
+
+	operations, err := loadOperationLog()
+	if err != nil {
+		return operation, err
+	}
+
+	operation.ID = 1
+	if len(operations) > 0 {
+		operation.ID = operations[len(operations)-1].ID + 1
+	}
+	operation.Time = time.Now()
+
+	line, err := json.Marshal(operation)
+	if err != nil {
+		return operation, err
+	}
+
+	file, err := os.OpenFile(ATHINA_OPLOG, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return operation, err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return operation, err
+}
+
+// Maps the id of every operation that has been undone to the id of the undo operation
+func undoneOperations(operations []Operation) map[int]int {
+
+	undone := map[int]int{}
+	for _, operation := range operations {
+		for _, id := range operation.Undoes {
+			undone[id] = operation.ID
+		}
+	}
+
+	return undone
+}
+
+// Rolls back the last n operations that have not been undone yet, newest first.
+// Files that changed again since an operation are a conflict, unless force is set
+func undoOperations(n int, force bool) ([]Operation, error) {
+
+	operations, err := loadOperationLog()
+	if err != nil {
+		return nil, err
+	}
+
+	undone := undoneOperations(operations)
+
+	var targets []Operation
+	for i := len(operations) - 1; i >= 0 && len(targets) < n; i-- {
+		operation := operations[i]
+		if len(operation.Undoes) > 0 {
+			continue
+		}
+		if _, ok := undone[operation.ID]; ok {
+			continue
+		}
+
+		// A switch rewrote the working files of a whole branch, which is not something undo can take back.
+		// Going back past it would roll back files that the switch has rewritten since
+		if operation.Switched != "" {
+			return nil, fmt.Errorf("operation %d switched from branch %q to %q, undo can not go back past a switch, use 'athina switch %s' instead", operation.ID, operation.Switched, operation.Branch, operation.Switched)
+		}
+
+		targets = append(targets, operation)
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("there are no operations to undo")
+	}
+
+	// Going from the newest to the oldest operation, every file has to be in the state the operation left it in
+	type expectedState struct {
+		object  operationContent
+		working operationContent
+	}
+	current := map[string]fileSnapshot{}
+	expected := map[string]expectedState{}
+	for _, operation := range targets {
+
+		// The objects an operation recorded belong to the branch it ran on
+		if branch := operation.branch(); branch != currentBranch {
+			return nil, fmt.Errorf("operation %d was recorded on branch %q, switch to it before undoing it", operation.ID, branch)
+		}
+
+		for _, file := range operation.Files {
+
+			state, ok := expected[file.Filename]
+			if !ok {
+				object, err := readOptionalAthinaObject(file.Filename)
+				if err != nil {
+					return nil, err
+				}
+				working, err := readOptionalFile(file.Filename)
+				if err != nil {
+					return nil, err
+				}
+
+				current[file.Filename] = fileSnapshot{object: object, working: working}
+				state = expectedState{object: operationContent{content: object}, working: operationContent{content: working}}
+			}
+
+			conflict := state.object.identity() != file.objectAfter().identity() ||
+				(file.Working && state.working.identity() != file.workingAfter().identity())
+			if conflict && !force {
+				return nil, &FileError{Filename: file.Filename, Err: fmt.Errorf("%w since operation %d, use --force to undo anyway", ErrDirtyWorkingFile, operation.ID)}
+			}
+
+			state.object = file.objectBefore()
+			if file.Working {
+				state.working = file.workingBefore()
+			}
+			expected[file.Filename] = state
+		}
+	}
+
+	// Files in .athina go back to how they were before the oldest operation that rewrote them.
+	// Whatever changed them since belongs to operations that are undone now or have been undone before, e.g. the trees those appended
+	restoredPaths := map[string]string{}
+	for _, operation := range targets {
+		for _, path := range operation.Paths {
+			restoredPaths[path.Path] = path.BeforeHash
+		}
+	}
+
+	// Everything checks out, roll back the files
+	undoOperation := Operation{Command: []string{"undo", fmt.Sprint(n)}, Branch: currentBranch}
+	for filename, state := range expected {
+
+		object, err := state.object.load()
+		if err != nil {
+			return nil, err
+		}
+		working, err := state.working.load()
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeOptionalAthinaObject(filename, object)
+		if err != nil {
+			return nil, err
+		}
+
+		workingChanged := !equalOptionalContent(current[filename].working, working)
+		if workingChanged {
+			err = writeOptionalFile(filename, working)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		file, err := newOperationFile(filename, current[filename], fileSnapshot{object: object, working: working}, workingChanged)
+		if err != nil {
+			return nil, err
+		}
+		undoOperation.Files = append(undoOperation.Files, file)
+	}
+
+	for path, hash := range restoredPaths {
+		var content *string
+		if hash != "" {
+			content, err = loadBlob(hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		current, err := readOptionalFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		operationPath := OperationPath{Path: path, AfterHash: hash}
+		operationPath.BeforeHash, err = storeBlob(current)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeOptionalFile(path, content)
+		if err != nil {
+			return nil, err
+		}
+		undoOperation.Paths = append(undoOperation.Paths, operationPath)
+	}
+
+	for _, operation := range targets {
+		undoOperation.Undoes = append(undoOperation.Undoes, operation.ID)
+	}
+
+	_, _, err = recordTree()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = appendOperation(undoOperation)
+	return targets, err
+}
+
+func printOperationLog(depth int) error {
+
+	operations, err := loadOperationLog()
+	if err != nil {
+		return err
+	}
+
+	undone := undoneOperations(operations)
+
+	for i := len(operations) - 1; i >= 0 && depth > 0; i-- {
+		operation := operations[i]
+
+		var filenames []string
+		for _, file := range operation.Files {
+			filenames = append(filenames, file.Filename)
+		}
+
+		line := fmt.Sprintf("%s %s athina %s (%s)",
+			colorize(COLOR_YELLOW, fmt.Sprintf("%4d", operation.ID)),
+			operation.Time.Local().Format("2006-01-02 15:04:05"),
+			strings.Join(operation.Command, " "),
+			strings.Join(filenames, ", "))
+
+		if by, ok := undone[operation.ID]; ok {
+			line += colorize(COLOR_RED, fmt.Sprintf(" [undone by %d]", by))
+		}
+
+		fmt.Println(line)
+		depth--
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Creates a repository in a temporary directory and makes it the working directory for the rest of the test
+func newTestRepository(t *testing.T) {
+
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv(ATHINA_DIR_ENV, "")
+	t.Setenv(ATHINA_GLOBAL_CONFIG_ENV, filepath.Join(dir, "global.json"))
+
+	root := filepath.Join(dir, "repository")
+	err := os.Mkdir(root, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(previous)
+	})
+
+	err = os.Chdir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCommand(t, "init")
+}
+
+// Runs athina with the arguments, just like the command line does, and fails the test on an error
+func runCommand(t *testing.T, args ...string) {
+
+	t.Helper()
+
+	err := run(args)
+	if err != nil {
+		t.Fatalf("athina %v failed: %v", args, err)
+	}
+}
+
+func writeTestFile(t *testing.T, filename string, content string) {
+
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err == nil {
+		err = os.WriteFile(filename, []byte(content), 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndoSquash(t *testing.T) {
+
+	newTestRepository(t)
+
+	for _, content := range []string{"1\n", "1\n2\n", "1\n2\n3\n"} {
+		writeTestFile(t, "f.txt", content)
+		runCommand(t, "update")
+	}
+
+	runCommand(t, "tag", "t1", "f.txt")
+	runCommand(t, "squash", "f.txt", "latest~1", "latest")
+	runCommand(t, "undo")
+
+	// The snapshots and the tag name the versions the squash replaced, which the undo brought back
+	runCommand(t, "checkout", "latest~1", "--dry-run")
+	runCommand(t, "show", "f.txt", "t1")
+
+	athinafile, err := loadAthinaFileObject("f.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(athinafile.Diffs) != 3 {
+		t.Errorf("undo left %d changes of f.txt, want 3", len(athinafile.Diffs))
+	}
+}
+
+func TestUndoStopsAtSwitch(t *testing.T) {
+
+	newTestRepository(t)
+
+	writeTestFile(t, "f.txt", "1\n")
+	runCommand(t, "update")
+	runCommand(t, "branch", "create", "feature")
+	runCommand(t, "switch", "feature")
+	runCommand(t, "switch", DEFAULT_BRANCH)
+
+	// The update happened before the switches, which rewrote the working files since
+	err := run([]string{"undo"})
+	if err == nil {
+		t.Fatal("undo went back past a switch")
+	}
+
+	if !athinaObjectExists("f.txt") {
+		t.Error("the refused undo still rolled back the update")
+	}
+
+	// Operations after the switch can be undone as usual
+	writeTestFile(t, "f.txt", "1\n2\n")
+	runCommand(t, "update")
+	runCommand(t, "undo")
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// Options that apply to every command, they have to be passed before the command itself
+type globalOptions struct {
+	quiet     bool
+	verbose   bool
+	logFormat string
+	logFile   string
+	repo      string
+}
+
+const GLOBAL_OPTIONS_USAGE = "athina [-q|--quiet] [-v|--verbose] [--log-format=text|json] [--log-file=path] [--repo=path] [command] [args]"
+
+var options globalOptions
+
+// Consumes the global options at the start of args and returns the remaining arguments
+func parseGlobalOptions(args []string) (globalOptions, []string, error) {
+
+	parsed := globalOptions{logFormat: "text"}
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+
+		name, value, hasValue := strings.Cut(args[0], "=")
+
+		// Options that take a value can either be passed as '--name=value' or as '--name value'
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if len(args) < 2 {
+				return "", &UsageError{Usage: GLOBAL_OPTIONS_USAGE}
+			}
+			args = args[1:]
+			return args[0], nil
+		}
+
+		switch name {
+		case "-q", "--quiet":
+			parsed.quiet = true
+		case "-v", "--verbose":
+			parsed.verbose = true
+		case "--log-format":
+			format, err := takeValue()
+			if err != nil {
+				return parsed, nil, err
+			}
+			if format != "text" && format != "json" {
+				return parsed, nil, &UsageError{Usage: GLOBAL_OPTIONS_USAGE}
+			}
+			parsed.logFormat = format
+		case "--log-file":
+			file, err := takeValue()
+			if err != nil {
+				return parsed, nil, err
+			}
+			parsed.logFile = file
+		case "--repo":
+			repo, err := takeValue()
+			if err != nil {
+				return parsed, nil, err
+			}
+			parsed.repo = repo
+		default:
+			return parsed, nil, &UsageError{Usage: GLOBAL_OPTIONS_USAGE}
+		}
+
+		args = args[1:]
+	}
+
+	if parsed.quiet && parsed.verbose {
+		return parsed, nil, &UsageError{Usage: GLOBAL_OPTIONS_USAGE + " (--quiet and --verbose are mutually exclusive)"}
+	}
+
+	return parsed, args, nil
+}
+
+// Removes every occurrence of the given boolean flags from args, and reports whether any of them was present
+func extractFlag(args []string, names ...string) (bool, []string) {
+
+	found := false
+	var rest []string
+	for _, arg := range args {
+		if slices.Contains(names, arg) {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return found, rest
+}
+
+// Removes a flag that takes a value ('--name value' or '--name=value') from args, and returns its value
+func extractFlagValue(args []string, name string) (string, bool, []string, error) {
+
+	value := ""
+	found := false
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == name {
+			if i+1 >= len(args) {
+				return "", false, nil, &UsageError{Usage: name + " requires a value"}
+			}
+			value = args[i+1]
+			found = true
+			i++
+			continue
+		}
+
+		if v, ok := strings.CutPrefix(arg, name+"="); ok {
+			value = v
+			found = true
+			continue
+		}
+
+		rest = append(rest, arg)
+	}
+
+	return value, found, rest, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	COLOR_RESET  = "\033[0m"
+	COLOR_RED    = "\033[31m"
+	COLOR_GREEN  = "\033[32m"
+	COLOR_YELLOW = "\033[33m"
+	COLOR_CYAN   = "\033[36m"
+)
+
+// Set while output is being sent through the pager, which is a terminal as far as colors are concerned
+var paging bool
+
+func isTerminal(file *os.File) bool {
+
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func useColor() bool {
+
+	switch config.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	return os.Getenv("NO_COLOR") == "" && (paging || isTerminal(os.Stdout))
+}
+
+func colorize(color string, text string) string {
+
+	if !useColor() {
+		return text
+	}
+
+	return color + text + COLOR_RESET
+}
+
+// Runs fn with its standard output sent through the configured pager, if there is one and we are writing to a terminal
+func withPager(fn func() error) error {
+
+	if config.Pager == "" || !isTerminal(os.Stdout) {
+		return fn()
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sh", "-c", config.Pager)
+	cmd.Stdin = reader
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Start()
+	if err != nil {
+		reader.Close()
+		writer.Close()
+		return err
+	}
+	reader.Close()
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	paging = true
+
+	fnErr := fn()
+
+	os.Stdout = stdout
+	paging = false
+	writer.Close()
+
+	err = cmd.Wait()
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return err
+}
+
+// Asks the user a yes/no question on the terminal, anything but an explicit yes counts as no
+func confirm(question string) bool {
+
+	fmt.Print(question + " [y/N] ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const PICK_USAGE = "athina pick [filename] [revision] [--onto filename]"
+
+var errPickHunksFailed = errors.New("hunks of the picked change could not be applied")
+
+// Reapplies a single recorded change of a file to the latest content of the target file, which may be the same file or another one.
+// The change is turned into a patch, so that it still applies when the lines around it have moved or changed a little.
+// Returns the hunks that could not be applied
+func AthinaPickChange(filename string, revision string, target string) ([]string, error) {
+
+	source, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := resolveRevision(source, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	var before, after string
+	err = replayAthinaFile(source, func(i int, filediff Filediff, current string, next string) error {
+		if i == index {
+			before, after = current, next
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	picked := source.Diffs[index]
+	if before == after {
+		return nil, &FileError{Filename: filename, Err: fmt.Errorf("change %s does not change the content, there is nothing to pick", shortenHash(picked.Hash))}
+	}
+
+	athinafile, err := loadAthinaFileObject(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if athinafile.isDeleted() {
+		return nil, &FileError{Filename: target, Err: fmt.Errorf("%w, the file has been deleted", os.ErrNotExist)}
+	}
+
+	// The result is written to the working file, so it must not hold anything that has not been recorded
+	delta, err := diffAthinaFileObjectAndFile(athinafile, target)
+	if err != nil {
+		return nil, err
+	}
+	if !isDeltaDiffEmpty(delta) {
+		return nil, &FileError{Filename: target, Err: ErrDirtyWorkingFile}
+	}
+
+	current, err := emulateDeltaDiffsFromAthinaFileObject(athinafile)
+	if err != nil {
+		return nil, err
+	}
+
+	dmp := newDiffMatchPatch()
+	patches := dmp.PatchMake(before, after)
+	result, applied := dmp.PatchApply(patches, current)
+
+	var failed []string
+	for i, ok := range applied {
+		if !ok {
+			header, _, _ := strings.Cut(dmp.PatchToText(patches[i:i+1]), "\n")
+			failed = append(failed, header)
+		}
+	}
+
+	if len(failed) == len(patches) {
+		return failed, &FileError{Filename: target, Err: fmt.Errorf("none of the %d %s", len(patches), errPickHunksFailed)}
+	}
+
+	message := "Picked " + shortenHash(picked.Hash)
+	if filename != target {
+		message += " from " + filename
+	}
+
+	delta, err = diffAthinaFileObjectAndString(athinafile, result)
+	if err != nil {
+		return failed, err
+	}
+
+	filediff := newFilediff(newFileDiffOptions{change: AthinaFileChangeActionPick, delta: delta, message: message})
+
+	slog.Debug("picked change", "file", filename, "hash", picked.Hash, "onto", target, "failed", len(failed))
+
+	err = AthinaModifyFile(athinafile, []Filediff{filediff})
+	if err != nil {
+		return failed, err
+	}
+
+	err = touchFile(target)
+	if err != nil {
+		return failed, err
+	}
+
+	err = os.WriteFile(target, []byte(result), 0644)
+	if err != nil {
+		return failed, &FileError{Filename: target, Err: err}
+	}
+
+	if len(failed) > 0 {
+		return failed, &FileError{Filename: target, Err: fmt.Errorf("%d of the %d %s, the others were recorded", len(failed), len(patches), errPickHunksFailed)}
+	}
+
+	return nil, nil
+}
+
+func handlePickCommand(args []string) error {
+
+	onto, found, args, err := extractFlagValue(args, "--onto")
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 2 {
+		return &UsageError{Usage: PICK_USAGE}
+	}
+
+	filename, err := resolveRepositoryPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	target := filename
+	if found {
+		target, err = resolveRepositoryPath(onto)
+		if err != nil {
+			return err
+		}
+	}
+
+	failed, err := AthinaPickChange(filename, args[1], target)
+	for _, hunk := range failed {
+		fmt.Println(colorize(COLOR_RED, "Failed hunk: ") + hunk)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Change " + args[1] + " of \"" + filename + "\" has been applied to \"" + target + "\"")
+
+	return nil
+}
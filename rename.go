@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const MV_USAGE = "athina mv [old filename] [new filename]"
+
+const DEFAULT_RENAME_THRESHOLD float64 = 0.5
+
+// Carries the history of a tracked file over to a new name, and records the rename together with the given delta.
+// The object under the old name is removed, the working file is left alone
+func AthinaRenameFile(oldFilename string, newFilename string, delta string) error {
+
+	athinafile, err := loadAthinaFileObject(oldFilename)
+	if err != nil {
+		return err
+	}
+
+	if athinaObjectExists(newFilename) {
+		return &FileError{Filename: newFilename, Err: fmt.Errorf("%w, it is already tracked", fs.ErrExist)}
+	}
+
+	filediff := newFilediff(newFileDiffOptions{change: AthinaFileChangeActionRename, delta: delta, from: oldFilename})
+	athinafile.Filename = newFilename
+
+	slog.Debug("renaming file", "from", oldFilename, "to", newFilename, "hash", filediff.Hash)
+
+	err = AthinaModifyFile(athinafile, []Filediff{filediff})
+	if err != nil {
+		return err
+	}
+
+	err = renameTaggedFile(oldFilename, newFilename)
+	if err != nil {
+		return err
+	}
+
+	err = renameTreeFile(oldFilename, newFilename)
+	if err != nil {
+		return err
+	}
+
+	return writeOptionalAthinaObject(oldFilename, nil)
+}
+
+// Moves a tracked file, both the working file and its history, to a new name
+func AthinaMoveFile(oldFilename string, newFilename string) error {
+
+	athinafile, err := loadAthinaFileObject(oldFilename)
+	if err != nil {
+		return err
+	}
+
+	if athinafile.isDeleted() {
+		return &FileError{Filename: oldFilename, Err: fmt.Errorf("%w, the file has been deleted", fs.ErrNotExist)}
+	}
+
+	if _, err := os.Stat(newFilename); err == nil {
+		return &FileError{Filename: newFilename, Err: fs.ErrExist}
+	}
+
+	if athinaObjectExists(newFilename) {
+		return &FileError{Filename: newFilename, Err: fmt.Errorf("%w, it is already tracked", fs.ErrExist)}
+	}
+
+	// The working file may already have been moved by hand, in which case only the history has to follow
+	if _, err := os.Stat(oldFilename); err == nil {
+		err = touchFile(oldFilename)
+		if err != nil {
+			return err
+		}
+
+		err = touchFile(newFilename)
+		if err != nil {
+			return err
+		}
+
+		err = os.MkdirAll(filepath.Dir(newFilename), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = os.Rename(oldFilename, newFilename)
+		if err != nil {
+			return &FileError{Filename: oldFilename, Err: err}
+		}
+	}
+
+	// Changes that were not recorded yet stay unrecorded, they show up as a modification on the next update
+	return AthinaRenameFile(oldFilename, newFilename, "")
+}
+
+// Returns how much of the two texts is the same, from 0 for nothing to 1 for identical, comparing them line by line.
+// Empty texts are not similar to anything, as every empty file would otherwise look like a rename of every other one
+func similarity(a string, b string) float64 {
+
+	if a == "" || b == "" {
+		return 0
+	}
+
+	same, total := 0, 0
+	for _, diff := range diffLines(a, b) {
+		total += len(diff.Lines)
+		if diff.Type == diffmatchpatch.DiffEqual {
+			same += 2 * len(diff.Lines)
+			total += len(diff.Lines)
+		}
+	}
+
+	return float64(same) / float64(total)
+}
+
+// Pairs up deleted and new files whose content is similar enough to be renames, best matches first.
+// Returns the rename changes, and the deletions and additions that are left over
+func detectRenames(deleted []AthinaFileChange, added []AthinaFileChange) ([]AthinaFileChange, []AthinaFileChange, []AthinaFileChange, error) {
+
+	threshold := config.Rename.Threshold
+	if threshold <= 0 || len(deleted) == 0 || len(added) == 0 {
+		return nil, deleted, added, nil
+	}
+
+	// The last recorded content of every deleted file, and the working content of every new file
+	deletedContents := make([]string, len(deleted))
+	for i, change := range deleted {
+		athinafile, err := loadAthinaFileObject(change.filename)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		deletedContents[i], err = emulateDeltaDiffsFromAthinaFileObject(athinafile)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	addedContents := make([]string, len(added))
+	for i, change := range added {
+		content, err := os.ReadFile(change.filename)
+		if err != nil {
+			return nil, nil, nil, &FileError{Filename: change.filename, Err: err}
+		}
+		addedContents[i] = string(content)
+	}
+
+	pairedDeleted := make([]bool, len(deleted))
+	pairedAdded := make([]bool, len(added))
+	var renames []AthinaFileChange
+	for {
+		best, bestDeleted, bestAdded := threshold, -1, -1
+		for i := range deleted {
+			for j := range added {
+				if pairedDeleted[i] || pairedAdded[j] {
+					continue
+				}
+				if score := similarity(deletedContents[i], addedContents[j]); score >= best && (bestDeleted < 0 || score > best) {
+					best, bestDeleted, bestAdded = score, i, j
+				}
+			}
+		}
+
+		if bestDeleted < 0 {
+			break
+		}
+
+		pairedDeleted[bestDeleted] = true
+		pairedAdded[bestAdded] = true
+		slog.Debug("detected rename", "from", deleted[bestDeleted].filename, "to", added[bestAdded].filename, "similarity", best)
+		renames = append(renames, AthinaFileChange{action: AthinaFileChangeActionRename, from: deleted[bestDeleted].filename, filename: added[bestAdded].filename})
+	}
+
+	var remainingDeleted, remainingAdded []AthinaFileChange
+	for i, change := range deleted {
+		if !pairedDeleted[i] {
+			remainingDeleted = append(remainingDeleted, change)
+		}
+	}
+	for j, change := range added {
+		if !pairedAdded[j] {
+			remainingAdded = append(remainingAdded, change)
+		}
+	}
+
+	return renames, remainingDeleted, remainingAdded, nil
+}
+
+func handleMvCommand(args []string) error {
+
+	if len(args) != 2 {
+		return &UsageError{Usage: MV_USAGE}
+	}
+
+	files, err := resolveRepositoryPaths(args)
+	if err != nil {
+		return err
+	}
+
+	if files[0] == files[1] {
+		return &UsageError{Usage: MV_USAGE + " (the new filename has to differ from the old one)"}
+	}
+
+	err = AthinaMoveFile(files[0], files[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("File \"" + files[0] + "\" has been moved to \"" + files[1] + "\"")
+
+	return nil
+}
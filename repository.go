@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variable that can point athina at a repository, just like the --repo option
+const ATHINA_DIR_ENV = "ATHINA_DIR"
+
+// The directory that contains the .athina folder, and the directory athina was invoked from.
+// Once the repository has been opened the process runs from the repository root,
+// so paths given on the command line have to be resolved with resolveRepositoryPath
+var repositoryRoot string
+var invocationDirectory string
+
+// Finds the repository root that was explicitly asked for through --repo or ATHINA_DIR, if any.
+// Both may either name the directory containing the .athina folder, or the .athina folder itself
+func explicitRepositoryRoot() string {
+
+	repo := options.repo
+	if repo == "" {
+		repo = os.Getenv(ATHINA_DIR_ENV)
+	}
+
+	if repo == "" {
+		return ""
+	}
+
+	repo = filepath.Clean(repo)
+	if filepath.Base(repo) == ATHINA_FOLDER {
+		repo = filepath.Dir(repo)
+	}
+
+	return repo
+}
+
+// Walks from the start directory up through its parents, looking for a directory that contains a .athina folder
+func findAthinaRepository(start string) (string, error) {
+
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ATHINA_FOLDER)); err == nil && info.IsDir() {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrRepositoryMissing
+		}
+		dir = parent
+	}
+}
+
+// Locates the repository (or, when creating one, the directory it should live in) and moves the process into its root
+func openAthinaRepository(create bool) error {
+
+	var err error
+	invocationDirectory, err = os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	root := explicitRepositoryRoot()
+
+	switch {
+	case create && root == "":
+		// 'athina init' without an explicit location creates the repository right where we are
+		root = invocationDirectory
+
+	case create:
+		// Use the explicit location as is
+
+	case root == "":
+		root, err = findAthinaRepository(invocationDirectory)
+		if err != nil {
+			return err
+		}
+
+	default:
+		// An explicitly named repository has to exist, we never search upwards from it
+		if info, err := os.Stat(filepath.Join(root, ATHINA_FOLDER)); err != nil || !info.IsDir() {
+			return ErrRepositoryMissing
+		}
+	}
+
+	repositoryRoot, err = filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("using repository", "root", repositoryRoot)
+
+	return os.Chdir(repositoryRoot)
+}
+
+// Converts a path given on the command line, relative to where athina was invoked, into a path relative to the repository root
+func resolveRepositoryPath(path string) (string, error) {
+
+	// Relative paths are taken from where athina was invoked, unless that is outside of the repository (e.g. with --repo),
+	// in which case they are taken from the repository root
+	if !filepath.IsAbs(path) {
+		base := invocationDirectory
+		if !isInsideDirectory(repositoryRoot, invocationDirectory) {
+			base = repositoryRoot
+		}
+		path = filepath.Join(base, path)
+	}
+
+	if !isInsideDirectory(repositoryRoot, path) {
+		return "", &FileError{Filename: path, Err: errors.New("path is outside of the repository at " + repositoryRoot)}
+	}
+
+	relative, err := filepath.Rel(repositoryRoot, path)
+	if err != nil {
+		return "", &FileError{Filename: path, Err: err}
+	}
+
+	// The files in .athina belong to athina itself and are never tracked
+	if isInsideDirectory(filepath.Join(repositoryRoot, ATHINA_FOLDER), path) {
+		return "", &UsageError{Usage: "athina [command] [args] (" + filepath.ToSlash(relative) + " is inside of " + ATHINA_FOLDER + ", name a file of the working tree)"}
+	}
+
+	return filepath.ToSlash(relative), nil
+}
+
+// Converts a path given on the command line that may lie outside of the repository, such as an export directory,
+// into an absolute path. Relative paths are taken from where athina was invoked
+func resolveInvocationPath(path string) string {
+
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(invocationDirectory, path)
+}
+
+func isInsideDirectory(dir string, path string) bool {
+
+	relative, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+// Resolves every path in paths with resolveRepositoryPath
+func resolveRepositoryPaths(paths []string) ([]string, error) {
+
+	var resolved []string
+	for _, path := range paths {
+		relative, err := resolveRepositoryPath(path)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, relative)
+	}
+
+	return resolved, nil
+}
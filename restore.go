@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Recreates a file that no longer exists in the working directory from its recorded history.
+// Without a hash the last content before the deletion is used, deletions themselves do not change the content
+func AthinaRestoreFile(filename string, hash string) error {
+
+	athinafile, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return err
+	}
+
+	// Restoring never overwrites anything, that is what revert is for
+	if _, err := os.Stat(filename); err == nil {
+		return &FileError{Filename: filename, Err: fs.ErrExist}
+	}
+
+	var content string
+	if hash == "" {
+		content, err = emulateDeltaDiffsFromAthinaFileObject(athinafile)
+	} else {
+		content, err = emulateDeltaDiffsUntilHash(athinafile, hash)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Record the restore, going from the latest recorded state to the restored content
+	delta, err := diffAthinaFileObjectAndString(athinafile, content)
+	if err != nil {
+		return err
+	}
+
+	filediff := newFilediff(newFileDiffOptions{change: AthinaFileChangeActionRestore, delta: delta})
+	err = AthinaModifyFile(athinafile, []Filediff{filediff})
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("writing restored content", "file", filename, "hash", hash)
+
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = touchFile(filename)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(filename, []byte(content), 0644)
+	if err != nil {
+		return &FileError{Filename: filename, Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const PRUNE_USAGE = "athina prune [--dry-run] [filename(s)]"
+
+// A retention rule decides which versions of the files matching its pattern are kept.
+// Rules are written as a pattern followed by settings, e.g. "*.log last=10" or "notes/* days=7 daily=30 weekly=52".
+// A version is kept when any of the settings keeps it:
+//
+//	last=N   : the N most recent versions
+//	days=X   : every version from the last X days
+//	daily=D  : the last version of every day, for the last D days
+//	weekly=W : the last version of every week, for the last W weeks
+//
+// The most recent version is always kept
+type retentionRule struct {
+	pattern string
+	last    int
+	days    int
+	daily   int
+	weekly  int
+}
+
+func parseRetentionRule(rule string) (retentionRule, error) {
+
+	fields := strings.Fields(rule)
+	if len(fields) < 2 {
+		return retentionRule{}, fmt.Errorf("invalid retention rule %q, expected a pattern followed by settings such as last=10", rule)
+	}
+
+	parsed := retentionRule{pattern: fields[0]}
+	if _, err := path.Match(parsed.pattern, ""); err != nil {
+		return retentionRule{}, fmt.Errorf("invalid pattern in retention rule %q: %w", rule, err)
+	}
+
+	for _, field := range fields[1:] {
+		name, value, _ := strings.Cut(field, "=")
+
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return retentionRule{}, fmt.Errorf("invalid setting %q in retention rule %q, expected a positive number", field, rule)
+		}
+
+		switch name {
+		case "last":
+			parsed.last = n
+		case "days":
+			parsed.days = n
+		case "daily":
+			parsed.daily = n
+		case "weekly":
+			parsed.weekly = n
+		default:
+			return retentionRule{}, fmt.Errorf("unknown setting %q in retention rule %q, expected last, days, daily or weekly", name, rule)
+		}
+	}
+
+	return parsed, nil
+}
+
+func validateRetentionRule(rule string) error {
+	_, err := parseRetentionRule(rule)
+	return err
+}
+
+// Patterns without a slash match the name of the file in any directory, other patterns match the whole path
+func (r retentionRule) matches(filename string) bool {
+
+	if !strings.Contains(r.pattern, "/") {
+		filename = path.Base(filename)
+	}
+
+	matched, _ := path.Match(r.pattern, filename)
+	return matched
+}
+
+// Returns the retention rule for the file. Later rules override earlier ones, so repository rules win over global ones
+func (c Config) RetentionRule(filename string) (retentionRule, bool, error) {
+
+	var found retentionRule
+	ok := false
+	for _, rule := range c.Retention.Rules {
+		parsed, err := parseRetentionRule(rule)
+		if err != nil {
+			return retentionRule{}, false, &ConfigError{Path: "retention.rules", Err: err}
+		}
+
+		if parsed.matches(filename) {
+			found, ok = parsed, true
+		}
+	}
+
+	return found, ok, nil
+}
+
+// Returns which of the Filediffs the rule keeps
+func (r retentionRule) retained(diffs []Filediff, now time.Time) []bool {
+
+	keep := make([]bool, len(diffs))
+	if len(diffs) == 0 {
+		return keep
+	}
+
+	seenDays := map[string]bool{}
+	seenWeeks := map[string]bool{}
+
+	// Walk from the newest version back, so that the first version seen for a day or a week is the last one of it
+	for i := len(diffs) - 1; i >= 0; i-- {
+		t := diffs[i].Time
+		age := now.Sub(t)
+
+		if len(diffs)-i <= r.last {
+			keep[i] = true
+		}
+
+		// Versions recorded before times were tracked are older than any window
+		if t.IsZero() {
+			continue
+		}
+
+		if age <= time.Duration(r.days)*24*time.Hour {
+			keep[i] = true
+		}
+
+		day := t.Local().Format("2006-01-02")
+		if age <= time.Duration(r.daily)*24*time.Hour && !seenDays[day] {
+			keep[i] = true
+		}
+		seenDays[day] = true
+
+		year, week := t.Local().ISOWeek()
+		key := fmt.Sprintf("%d-%d", year, week)
+		if age <= time.Duration(r.weekly)*7*24*time.Hour && !seenWeeks[key] {
+			keep[i] = true
+		}
+		seenWeeks[key] = true
+	}
+
+	keep[len(diffs)-1] = true
+
+	return keep
+}
+
+// Drops every Filediff that is not kept. The origin is moved forward to the oldest kept version,
+// and kept Filediffs that follow dropped ones get a delta that spans the dropped ones.
+// Kept Filediffs keep their hashes, as they still lead to the same content: a hash identifies a version, not its delta (see Filediff)
+func pruneAthinaFile(athinafile AthinaFile, keep []bool) (AthinaFile, error) {
+
+	var states []string
+	err := replayAthinaFile(athinafile, func(index int, filediff Filediff, before string, after string) error {
+		states = append(states, after)
+		return nil
+	})
+	if err != nil {
+		return athinafile, err
+	}
+
+	dmp := newDiffMatchPatch()
+	var diffs []Filediff
+	previous := -1
+	for i, filediff := range athinafile.Diffs {
+		if !keep[i] {
+			continue
+		}
+
+		switch {
+		case previous < 0:
+			// The oldest kept version becomes the origin, and just like any first Filediff carries no delta
+			athinafile.Origin = states[i]
+			filediff.Delta = ""
+		case previous != i-1:
+			filediff.Delta = dmp.DiffToDelta(dmp.DiffMain(states[previous], states[i], config.Diff.CheckLines))
+		}
+
+		diffs = append(diffs, filediff)
+		previous = i
+	}
+
+	athinafile.Diffs = diffs
+
+	return athinafile, nil
+}
+
+// Applies the retention rules to the files, every tracked file if none are given, and returns how many Filediffs were dropped.
+// With dryRun nothing is written
+func pruneAthinaFiles(filenames []string, dryRun bool, log bool) (int, error) {
+
+	if len(filenames) == 0 {
+		var err error
+		filenames, err = AthinaListFiles()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	total := 0
+	for _, filename := range filenames {
+
+		rule, ok, err := config.RetentionRule(filename)
+		if err != nil {
+			return total, err
+		}
+		if !ok {
+			continue
+		}
+
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return total, err
+		}
+
+		keep := rule.retained(athinafile.Diffs, now)
+
+		// Tagged versions are kept whatever the rule says, so that their tags keep resolving
+		tagged, err := taggedFilediffs(athinafile)
+		if err != nil {
+			return total, err
+		}
+		for i := range keep {
+			keep[i] = keep[i] || tagged[i]
+		}
+
+		dropped := 0
+		for _, kept := range keep {
+			if !kept {
+				dropped++
+			}
+		}
+		if dropped == 0 {
+			continue
+		}
+		total += dropped
+
+		if log {
+			verb := "Dropped"
+			if dryRun {
+				verb = "Would drop"
+			}
+			fmt.Printf("%s %d of %d changes of \"%s\" (%s)\n", verb, dropped, len(keep), filename, rule.pattern)
+		}
+
+		if dryRun {
+			continue
+		}
+
+		// A dropped version is folded into the next kept one, which takes its place in the snapshots
+		replaced := map[string]string{}
+		for i := len(keep) - 1; i >= 0; i-- {
+			if keep[i] {
+				continue
+			}
+			next := i + 1
+			for !keep[next] {
+				next++
+			}
+			replaced[athinafile.Diffs[i].Hash] = athinafile.Diffs[next].Hash
+		}
+
+		athinafile, err = pruneAthinaFile(athinafile, keep)
+		if err != nil {
+			return total, err
+		}
+
+		slog.Debug("pruned history", "file", filename, "dropped", dropped, "origin", athinafile.Diffs[0].Hash)
+
+		err = athinafile.Save()
+		if err != nil {
+			return total, err
+		}
+
+		err = retargetTrees(filename, replaced)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+func handlePruneCommand(args []string) error {
+
+	dryRun, args := extractFlag(args, "--dry-run", "-n")
+
+	filenames, err := resolveRepositoryPaths(args)
+	if err != nil {
+		return err
+	}
+
+	dropped, err := pruneAthinaFiles(filenames, dryRun, true)
+	if err != nil {
+		return err
+	}
+
+	if dropped == 0 {
+		fmt.Println("Nothing to prune")
+	}
+
+	return nil
+}
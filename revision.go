@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Selects a version of a file either by a revision (see resolveRevision), or by a point in time
+type versionSpec struct {
+	revision string
+	at       string
+}
+
+func (s versionSpec) isEmpty() bool {
+	return s.revision == "" && s.at == ""
+}
+
+func (s versionSpec) String() string {
+
+	if s.at != "" {
+		return s.at
+	}
+
+	return s.revision
+}
+
+// Returns the index of the Filediff that the spec selects. An empty spec selects the latest Filediff
+func resolveVersion(athinafile AthinaFile, spec versionSpec) (int, error) {
+
+	if spec.at != "" {
+		t, err := parseTimeSpec(spec.at, time.Now())
+		if err != nil {
+			return -1, err
+		}
+		return findFilediffAtTime(athinafile, t)
+	}
+
+	if spec.revision == "" {
+		return len(athinafile.Diffs) - 1, nil
+	}
+
+	return resolveRevision(athinafile, spec.revision)
+}
+
+// Symbolic names for the latest and the first Filediff of a file
+const (
+	REVISION_LATEST = "latest"
+	REVISION_ORIGIN = "origin"
+)
+
+// Returns the index of the Filediff that a revision refers to. A revision is a full hash, a tag, a unique prefix of a hash,
+// "latest" or "origin", optionally followed by "~n" to go n Filediffs further back, e.g. "latest~3"
+func resolveRevision(athinafile AthinaFile, revision string) (int, error) {
+
+	base, offset := revision, 0
+	if i := strings.LastIndex(revision, "~"); i >= 0 {
+		base = revision[:i]
+
+		var err error
+		offset, err = strconv.Atoi(revision[i+1:])
+		if revision[i+1:] == "" {
+			offset, err = 1, nil
+		}
+		if err != nil || offset < 0 {
+			return -1, &UsageError{Usage: fmt.Sprintf("invalid revision %q, the offset after '~' must be a positive number", revision)}
+		}
+	}
+
+	index, err := resolveRevisionBase(athinafile, base)
+	if err != nil {
+		return -1, err
+	}
+
+	if index-offset < 0 {
+		return -1, &FileError{Filename: athinafile.Filename, Err: fmt.Errorf("%w: %q goes back further than the origin", ErrHashNotFound, revision)}
+	}
+
+	return index - offset, nil
+}
+
+func resolveRevisionBase(athinafile AthinaFile, base string) (int, error) {
+
+	if len(athinafile.Diffs) == 0 {
+		return -1, &FileError{Filename: athinafile.Filename, Err: ErrHashNotFound}
+	}
+
+	switch base {
+	case REVISION_LATEST, "":
+		return len(athinafile.Diffs) - 1, nil
+	case REVISION_ORIGIN:
+		return 0, nil
+	}
+
+	// An exact match always wins, then a tag, otherwise the prefix has to match exactly one hash
+	var candidates []int
+	for i, filediff := range athinafile.Diffs {
+		if filediff.Hash == base {
+			return i, nil
+		}
+		if strings.HasPrefix(filediff.Hash, base) {
+			candidates = append(candidates, i)
+		}
+	}
+
+	if validateTagName(base) == nil {
+		index, found, err := resolveTag(athinafile, base)
+		if err != nil || found {
+			return index, err
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return -1, &FileError{Filename: athinafile.Filename, Err: ErrHashNotFound}
+	case 1:
+		return candidates[0], nil
+	}
+
+	var hashes []string
+	for _, i := range candidates {
+		hashes = append(hashes, athinafile.Diffs[i].Hash)
+	}
+
+	return -1, &AmbiguousRevisionError{Filename: athinafile.Filename, Revision: base, Candidates: hashes}
+}
+
+// Returns the index of the last Filediff that was recorded at or before t.
+// Filediffs from before times were recorded count as older than anything else
+func findFilediffAtTime(athinafile AthinaFile, t time.Time) (int, error) {
+
+	found := -1
+	for i, filediff := range athinafile.Diffs {
+		if filediff.Time.After(t) {
+			break
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return -1, &FileError{Filename: athinafile.Filename, Err: fmt.Errorf("%w at or before %s", ErrHashNotFound, t.Format("2006-01-02 15:04:05"))}
+	}
+
+	return found, nil
+}
+
+// Parses the '[filename] [revision] | [filename] --at [time]' arguments that select a version of a file.
+// The filename is resolved relative to the repository root
+func parseFileVersionArgs(args []string, usage string) (string, versionSpec, error) {
+
+	at, found, rest, err := extractFlagValue(args, "--at")
+	if err != nil {
+		return "", versionSpec{}, err
+	}
+
+	if len(rest) < 1 || len(rest) > 2 || (found && len(rest) == 2) {
+		return "", versionSpec{}, &UsageError{Usage: usage}
+	}
+
+	filename, err := resolveRepositoryPath(rest[0])
+	if err != nil {
+		return "", versionSpec{}, err
+	}
+
+	spec := versionSpec{at: at}
+	if len(rest) == 2 {
+		spec.revision = rest[1]
+	}
+
+	return filename, spec, nil
+}
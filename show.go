@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const DIFF_CONTEXT_LINES int = 3
+
+const SHOW_USAGE = "athina show [filename] [revision] | athina show [filename] --at [time]"
+const DIFF_USAGE = "athina diff [filename] [revision] | athina diff [filename] --at [time]"
+
+// Prints the content of a file as it was recorded at the selected version
+func AthinaShowFile(filename string, spec versionSpec) error {
+
+	athinafile, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return err
+	}
+
+	index, err := resolveVersion(athinafile, spec)
+	if err != nil {
+		return err
+	}
+
+	content, err := emulateDeltaDiffsUntilIndex(athinafile, index)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(content)
+	return nil
+}
+
+// Prints the difference between the selected version of a file and the file in the working directory
+func AthinaDiffFile(filename string, spec versionSpec) error {
+
+	athinafile, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return err
+	}
+
+	index, err := resolveVersion(athinafile, spec)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := emulateDeltaDiffsUntilIndex(athinafile, index)
+	if err != nil {
+		return err
+	}
+
+	// A file that no longer exists is diffed against nothing
+	working := ""
+	content, err := os.ReadFile(filename)
+	if err == nil {
+		working = string(content)
+	} else if !os.IsNotExist(err) {
+		return &FileError{Filename: filename, Err: err}
+	}
+
+	printUnifiedDiff(recorded, working, filename+"@"+shortenHash(athinafile.Diffs[index].Hash), filename)
+	return nil
+}
+
+// A single line of a line diff, with its line numbers in the old and the new text
+type unifiedDiffLine struct {
+	op      diffmatchpatch.Operation
+	text    string
+	oldLine int
+	newLine int
+}
+
+// Prints the line diff between two texts in the unified diff format, with a few lines of context around every change
+func printUnifiedDiff(from string, to string, fromLabel string, toLabel string) {
+
+	var lines []unifiedDiffLine
+	oldLine, newLine := 1, 1
+	for _, diff := range diffLines(from, to) {
+		for _, text := range diff.Lines {
+			lines = append(lines, unifiedDiffLine{op: diff.Type, text: strings.TrimRight(text, "\r\n"), oldLine: oldLine, newLine: newLine})
+			switch diff.Type {
+			case diffmatchpatch.DiffEqual:
+				oldLine++
+				newLine++
+			case diffmatchpatch.DiffDelete:
+				oldLine++
+			case diffmatchpatch.DiffInsert:
+				newLine++
+			}
+		}
+	}
+
+	header := false
+	for start := 0; start < len(lines); {
+
+		// Find the next change, and grow the hunk for as long as changes are close enough to share their context
+		first := start
+		for first < len(lines) && lines[first].op == diffmatchpatch.DiffEqual {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		last := first
+		for i := first; i < len(lines) && i <= last+2*DIFF_CONTEXT_LINES; i++ {
+			if lines[i].op != diffmatchpatch.DiffEqual {
+				last = i
+			}
+		}
+
+		begin := max(first-DIFF_CONTEXT_LINES, 0)
+		end := min(last+DIFF_CONTEXT_LINES+1, len(lines))
+		hunk := lines[begin:end]
+
+		if !header {
+			fmt.Println(colorize(COLOR_RED, "--- "+fromLabel))
+			fmt.Println(colorize(COLOR_GREEN, "+++ "+toLabel))
+			header = true
+		}
+
+		oldCount, newCount := 0, 0
+		for _, line := range hunk {
+			if line.op != diffmatchpatch.DiffInsert {
+				oldCount++
+			}
+			if line.op != diffmatchpatch.DiffDelete {
+				newCount++
+			}
+		}
+
+		// An empty side is numbered after the line it follows, e.g. "-0,0" for an empty old text
+		oldStart, newStart := hunk[0].oldLine, hunk[0].newLine
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Println(colorize(COLOR_CYAN, fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, oldCount, newStart, newCount)))
+
+		for _, line := range hunk {
+			switch line.op {
+			case diffmatchpatch.DiffEqual:
+				fmt.Println(" " + line.text)
+			case diffmatchpatch.DiffDelete:
+				fmt.Println(colorize(COLOR_RED, "-"+line.text))
+			case diffmatchpatch.DiffInsert:
+				fmt.Println(colorize(COLOR_GREEN, "+"+line.text))
+			}
+		}
+
+		start = end
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+const SQUASH_USAGE = "athina squash [filename] [from revision] [to revision] [-m message] [--force]"
+
+// Squashing a revert would hide that an older version was brought back, so it has to be asked for explicitly
+var errSquashAcrossRevert = errors.New("the range contains a revert, use --force to squash it anyway")
+var errSquashAcrossTag = errors.New("the range would drop the tagged version")
+
+// Replaces the Filediffs from index from up to and including index to with a single Filediff
+// that takes the file from the state before the range straight to the state after it
+func squashAthinaFile(athinafile AthinaFile, from int, to int, message string, force bool) (AthinaFile, error) {
+
+	if !force {
+		for _, filediff := range athinafile.Diffs[from : to+1] {
+			if filediff.Change == AthinaFileChangeActionRevert {
+				return athinafile, &FileError{Filename: athinafile.Filename, Err: errSquashAcrossRevert}
+			}
+		}
+	}
+
+	var before, after string
+	err := replayAthinaFile(athinafile, func(index int, filediff Filediff, current string, next string) error {
+		if index == from {
+			before = current
+		}
+		if index == to {
+			after = next
+		}
+		return nil
+	})
+	if err != nil {
+		return athinafile, err
+	}
+
+	last := athinafile.Diffs[to]
+	if message == "" {
+		message = fmt.Sprintf("Squashed %d changes", to-from+1)
+	}
+
+	squashed := newFilediff(newFileDiffOptions{
+		deleted: last.Deleted && !last.Added,
+		added:   last.Added && !last.Deleted,
+		change:  AthinaFileChangeActionSquash,
+		message: message,
+	})
+
+	// The first Filediff has no delta of its own, its content lives in the origin
+	if from == 0 {
+		athinafile.Origin = after
+	} else {
+		dmp := newDiffMatchPatch()
+		squashed.Delta = dmp.DiffToDelta(dmp.DiffMain(before, after, config.Diff.CheckLines))
+	}
+
+	// Keep the squashed change where the range ended in time, so that selecting versions by time still finds it
+	squashed.Time = last.Time
+	squashed.Hash = squashed.getHash()
+
+	diffs := append([]Filediff{}, athinafile.Diffs[:from]...)
+	diffs = append(diffs, squashed)
+	diffs = append(diffs, athinafile.Diffs[to+1:]...)
+	athinafile.Diffs = diffs
+
+	return athinafile, nil
+}
+
+func AthinaSquashFile(filename string, fromRevision string, toRevision string, message string, force bool) (Filediff, error) {
+
+	athinafile, err := loadAthinaFileObject(filename)
+	if err != nil {
+		return Filediff{}, err
+	}
+
+	from, err := resolveRevision(athinafile, fromRevision)
+	if err != nil {
+		return Filediff{}, err
+	}
+
+	to, err := resolveRevision(athinafile, toRevision)
+	if err != nil {
+		return Filediff{}, err
+	}
+
+	if from >= to {
+		return Filediff{}, &UsageError{Usage: SQUASH_USAGE + " (the from revision has to be older than the to revision)"}
+	}
+
+	// A tagged version inside the range would be gone, only the version the range ends with survives as the squashed change
+	tagged, err := taggedFilediffs(athinafile)
+	if err != nil {
+		return Filediff{}, err
+	}
+	for i := from; i < to; i++ {
+		if tagged[i] {
+			return Filediff{}, &FileError{Filename: filename, Err: fmt.Errorf("%w %s", errSquashAcrossTag, shortenHash(athinafile.Diffs[i].Hash))}
+		}
+	}
+
+	var replaced []string
+	for _, filediff := range athinafile.Diffs[from : to+1] {
+		replaced = append(replaced, filediff.Hash)
+	}
+
+	athinafile, err = squashAthinaFile(athinafile, from, to, message, force)
+	if err != nil {
+		return Filediff{}, err
+	}
+
+	squashed := athinafile.Diffs[from]
+	slog.Debug("squashed changes", "file", filename, "from", from, "to", to, "hash", squashed.Hash)
+
+	err = athinafile.Save()
+	if err != nil {
+		return Filediff{}, err
+	}
+
+	// The squashed change leads to the same content as the last version of the range, so its tags move over.
+	// Hashes do not depend on the Filediffs before them, so the versions after the range keep theirs
+	err = retargetTags(filename, map[string]string{replaced[len(replaced)-1]: squashed.Hash})
+	if err != nil {
+		return Filediff{}, err
+	}
+
+	// Snapshots that named any version of the range now get the squashed change, which stands in for all of them
+	trees := map[string]string{}
+	for _, hash := range replaced {
+		trees[hash] = squashed.Hash
+	}
+
+	return squashed, retargetTrees(filename, trees)
+}
+
+func handleSquashCommand(args []string) error {
+
+	force, args := extractFlag(args, "--force", "-f")
+
+	message, _, args, err := extractFlagValue(args, "-m")
+	if err != nil {
+		return err
+	}
+
+	if message == "" {
+		message, _, args, err = extractFlagValue(args, "--message")
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(args) != 3 {
+		return &UsageError{Usage: SQUASH_USAGE}
+	}
+
+	filename, err := resolveRepositoryPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	squashed, err := AthinaSquashFile(filename, args[1], args[2], message, force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Squashed the changes of \"" + filename + "\" into " + colorize(COLOR_YELLOW, squashed.Hash))
+
+	return nil
+}
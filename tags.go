@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tags give memorable names to versions. A tag maps filenames to the hash of a Filediff: a file tag names one version of one file,
+// a repository tag is a snapshot of the latest version of every tracked file that is not deleted. Tags are stored as .athina/tags/<name>.json,
+// and can be used wherever a revision is accepted. Only repository tags can be checked out as a snapshot
+type Tag struct {
+	Name       string            `json:"name"`
+	Time       time.Time         `json:"time"`
+	Repository bool              `json:"repository"`
+	Files      map[string]string `json:"files"`
+}
+
+const ATHINA_TAGS = ".athina/tags/"
+
+const TAG_USAGE = "athina tag [list] | athina tag [name] [filename] [revision] | athina tag delete [name]"
+
+func tagPath(name string) string {
+	return ATHINA_TAGS + name + ".json"
+}
+
+func validateTagName(name string) error {
+
+	if !validName.MatchString(name) || name == REVISION_LATEST || name == REVISION_ORIGIN || name == "list" || name == "delete" {
+		return &UsageError{Usage: TAG_USAGE + " (tag names may only contain letters, digits, '.', '_' and '-', must start with a letter or digit, and can not be latest, origin, list or delete)"}
+	}
+
+	return nil
+}
+
+func loadTag(name string) (Tag, error) {
+
+	if validateTagName(name) != nil {
+		return Tag{}, &FileError{Filename: name, Err: ErrTagNotFound}
+	}
+
+	content, err := os.ReadFile(tagPath(name))
+	if os.IsNotExist(err) {
+		return Tag{}, &FileError{Filename: name, Err: ErrTagNotFound}
+	}
+	if err != nil {
+		return Tag{}, err
+	}
+
+	var tag Tag
+	err = json.Unmarshal(content, &tag)
+	if err != nil {
+		return Tag{}, &CorruptObjectError{Filename: tagPath(name), Err: err}
+	}
+
+	return tag, nil
+}
+
+func (t Tag) Save() error {
+
+	err := touchPaths([]string{tagPath(t.Name)})
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(ATHINA_TAGS, 0755)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(tagPath(t.Name), append(content, '\n'), 0644)
+}
+
+// Lists every tag, sorted by name
+func listTags() ([]Tag, error) {
+
+	entries, err := os.ReadDir(ATHINA_TAGS)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		tag, err := loadTag(name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+// Tags a single version of a file, or with an empty filename the latest version of every tracked file
+func createTag(name string, filename string, revision string) (Tag, error) {
+
+	err := validateTagName(name)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	if _, err := os.Stat(tagPath(name)); err == nil {
+		return Tag{}, &FileError{Filename: name, Err: fmt.Errorf("%w, the tag already exists", fs.ErrExist)}
+	}
+
+	tag := Tag{Name: name, Time: time.Now(), Repository: filename == "", Files: map[string]string{}}
+
+	filenames := []string{filename}
+	if filename == "" {
+		filenames, err = AthinaListFiles()
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	for _, filename := range filenames {
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return Tag{}, err
+		}
+
+		if tag.Repository && athinafile.isDeleted() {
+			continue
+		}
+
+		index, err := resolveVersion(athinafile, versionSpec{revision: revision})
+		if err != nil {
+			return Tag{}, err
+		}
+
+		tag.Files[filename] = athinafile.Diffs[index].Hash
+	}
+
+	return tag, tag.Save()
+}
+
+func deleteTag(name string) error {
+
+	if _, err := loadTag(name); err != nil {
+		return err
+	}
+
+	_, err := moveToTrash("delete tag "+name, []string{tagPath(name)})
+	return err
+}
+
+// Returns the index of the Filediff that a tag names for the file. found is false when there is no such tag,
+// a tag that does not include the file, or whose version is no longer in its history, is an error
+func resolveTag(athinafile AthinaFile, name string) (int, bool, error) {
+
+	tag, err := loadTag(name)
+	if errors.Is(err, ErrTagNotFound) {
+		return -1, false, nil
+	}
+	if err != nil {
+		return -1, true, err
+	}
+
+	hash, ok := tag.Files[athinafile.Filename]
+	if !ok {
+		return -1, true, &FileError{Filename: athinafile.Filename, Err: fmt.Errorf("%w: tag %q does not include the file", ErrHashNotFound, name)}
+	}
+
+	for i, filediff := range athinafile.Diffs {
+		if filediff.Hash == hash {
+			return i, true, nil
+		}
+	}
+
+	return -1, true, &FileError{Filename: athinafile.Filename, Err: fmt.Errorf("%w: tag %q names %s", ErrHashNotFound, name, shortenHash(hash))}
+}
+
+// Maps the hash of every tagged version of the file to the names of its tags
+func tagsByHash(filename string) (map[string][]string, error) {
+
+	tags, err := listTags()
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := map[string][]string{}
+	for _, tag := range tags {
+		if hash, ok := tag.Files[filename]; ok {
+			byHash[hash] = append(byHash[hash], tag.Name)
+		}
+	}
+
+	return byHash, nil
+}
+
+// Returns which of the Filediffs of the file are tagged, and must therefore never be dropped from its history
+func taggedFilediffs(athinafile AthinaFile) ([]bool, error) {
+
+	byHash, err := tagsByHash(athinafile.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := make([]bool, len(athinafile.Diffs))
+	for i, filediff := range athinafile.Diffs {
+		_, tagged[i] = byHash[filediff.Hash]
+	}
+
+	return tagged, nil
+}
+
+// Points the tags of the file at new hashes, e.g. after the tagged version was squashed. renamed maps old hashes to new ones
+func retargetTags(filename string, renamed map[string]string) error {
+
+	tags, err := listTags()
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		hash, ok := tag.Files[filename]
+		if !ok {
+			continue
+		}
+
+		if newHash, ok := renamed[hash]; ok && newHash != hash {
+			tag.Files[filename] = newHash
+			err = tag.Save()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Moves the tags of a file along when it is renamed
+func renameTaggedFile(oldFilename string, newFilename string) error {
+
+	tags, err := listTags()
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if hash, ok := tag.Files[oldFilename]; ok {
+			delete(tag.Files, oldFilename)
+			tag.Files[newFilename] = hash
+			err = tag.Save()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func handleTagCommand(args []string) error {
+
+	if len(args) == 0 || args[0] == "list" {
+		tags, err := listTags()
+		if err != nil {
+			return err
+		}
+
+		for _, tag := range tags {
+			if !tag.Repository {
+				for filename, hash := range tag.Files {
+					fmt.Printf("%-20s %s %s %s\n", colorize(COLOR_CYAN, tag.Name), tag.Time.Local().Format("2006-01-02 15:04"), filename, colorize(COLOR_YELLOW, shortenHash(hash)))
+				}
+				continue
+			}
+			fmt.Printf("%-20s %s %d files\n", colorize(COLOR_CYAN, tag.Name), tag.Time.Local().Format("2006-01-02 15:04"), len(tag.Files))
+		}
+		return nil
+	}
+
+	if args[0] == "delete" {
+		if len(args) != 2 {
+			return &UsageError{Usage: TAG_USAGE}
+		}
+
+		err := deleteTag(args[1])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Tag \"" + args[1] + "\" has been moved to the trash")
+		return nil
+	}
+
+	if len(args) > 3 {
+		return &UsageError{Usage: TAG_USAGE}
+	}
+
+	filename, revision := "", ""
+	if len(args) >= 2 {
+		var err error
+		filename, err = resolveRepositoryPath(args[1])
+		if err != nil {
+			return err
+		}
+	}
+	if len(args) == 3 {
+		revision = args[2]
+	}
+
+	tag, err := createTag(args[0], filename, revision)
+	if err != nil {
+		return err
+	}
+
+	if filename == "" {
+		fmt.Printf("Tagged the latest version of %d files as \"%s\"\n", len(tag.Files), tag.Name)
+	} else {
+		fmt.Printf("Tagged %s of \"%s\" as \"%s\"\n", shortenHash(tag.Files[filename]), filename, tag.Name)
+	}
+
+	return nil
+}
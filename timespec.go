@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts that are accepted for absolute points in time, interpreted in the local time zone
+var timeSpecLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+var relativeTimeSpec = regexp.MustCompile(`^(\d+)\s*([a-z]+?)s?\s+ago$`)
+
+var relativeTimeUnits = map[string]time.Duration{
+	"s":      time.Second,
+	"sec":    time.Second,
+	"second": time.Second,
+	"m":      time.Minute,
+	"min":    time.Minute,
+	"minute": time.Minute,
+	"h":      time.Hour,
+	"hour":   time.Hour,
+	"d":      24 * time.Hour,
+	"day":    24 * time.Hour,
+	"w":      7 * 24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// Parses a point in time such as "2026-10-01 14:00", "yesterday" or "2 hours ago", relative to now
+func parseTimeSpec(spec string, now time.Time) (time.Time, error) {
+
+	// Keywords and relative times are matched case-insensitively, but layouts such as RFC3339 need their 'T' and 'Z' as given
+	spec = strings.TrimSpace(spec)
+	lower := strings.ToLower(spec)
+
+	switch lower {
+	case "now":
+		return now, nil
+	case "today":
+		year, month, day := now.Date()
+		return time.Date(year, month, day, 23, 59, 59, 0, now.Location()), nil
+	case "yesterday":
+		year, month, day := now.AddDate(0, 0, -1).Date()
+		return time.Date(year, month, day, 23, 59, 59, 0, now.Location()), nil
+	}
+
+	for _, layout := range timeSpecLayouts {
+		if t, err := time.ParseInLocation(layout, spec, now.Location()); err == nil {
+			// A bare date means the end of that day
+			if layout == "2006-01-02" {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+
+	if match := relativeTimeSpec.FindStringSubmatch(lower); match != nil {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		switch match[2] {
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		case "y", "year":
+			return now.AddDate(-n, 0, 0), nil
+		}
+
+		if unit, ok := relativeTimeUnits[match[2]]; ok {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+
+	return time.Time{}, &UsageError{Usage: fmt.Sprintf("can not parse time %q, use e.g. \"2026-10-01 14:00\", \"yesterday\" or \"2 hours ago\"", spec)}
+}
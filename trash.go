@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Destructive operations move what they would have deleted into .athina/trash instead.
+// Every operation gets its own entry, a directory holding the moved paths (relative to the repository root) and a trash.json describing them
+type TrashEntry struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+	Paths  []string  `json:"paths"`
+}
+
+const TRASH_ENTRY_META = "trash.json"
+const TRASH_ENTRY_FILES = "files"
+
+// The ids moveToTrash generates. Ids come from the command line, and anything else could point outside of the trash
+var validTrashID = regexp.MustCompile(`^[0-9]{8}-[0-9]{6}\.[0-9]{6}(-[0-9]+)?$`)
+
+func (e TrashEntry) dir() string {
+	return ATHINA_TRASH + e.ID
+}
+
+// Moves the paths into a new trash entry
+func moveToTrash(reason string, paths []string) (TrashEntry, error) {
+
+	now := time.Now()
+	entry := TrashEntry{ID: now.UTC().Format("20060102-150405.000000"), Time: now, Reason: reason}
+
+	// Two operations within the same microsecond still get their own entry
+	for i := 1; ; i++ {
+		if _, err := os.Stat(entry.dir()); os.IsNotExist(err) {
+			break
+		}
+		entry.ID = now.UTC().Format("20060102-150405.000000") + "-" + fmt.Sprint(i)
+	}
+
+	err := touchPaths(paths)
+	if err != nil {
+		return entry, err
+	}
+
+	// Packed objects have to become files of their own before they can be moved
+	err = unpackAthinaObjectPaths(paths)
+	if err != nil {
+		return entry, err
+	}
+
+	for _, path := range paths {
+		target := filepath.Join(entry.dir(), TRASH_ENTRY_FILES, path)
+
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err == nil {
+			err = os.Rename(path, target)
+		}
+
+		// Whatever has been moved already stays restorable, an entry that holds nothing is dropped again
+		if err != nil {
+			if len(entry.Paths) == 0 {
+				return entry, errors.Join(err, os.RemoveAll(entry.dir()))
+			}
+			return entry, errors.Join(err, entry.Save())
+		}
+
+		entry.Paths = append(entry.Paths, filepath.ToSlash(path))
+	}
+
+	slog.Debug("moved to trash", "id", entry.ID, "reason", reason, "paths", entry.Paths)
+
+	return entry, entry.Save()
+}
+
+func (e TrashEntry) Save() error {
+
+	content, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(filepath.Join(e.dir(), TRASH_ENTRY_META), content)
+}
+
+func loadTrashEntry(id string) (TrashEntry, error) {
+
+	var entry TrashEntry
+
+	if !validTrashID.MatchString(id) {
+		return entry, &FileError{Filename: id, Err: ErrTrashEntryNotFound}
+	}
+
+	content, err := os.ReadFile(filepath.Join(ATHINA_TRASH, id, TRASH_ENTRY_META))
+	if os.IsNotExist(err) {
+		return entry, &FileError{Filename: id, Err: ErrTrashEntryNotFound}
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	err = json.Unmarshal(content, &entry)
+	if err != nil {
+		return entry, &CorruptObjectError{Filename: filepath.Join(ATHINA_TRASH, id, TRASH_ENTRY_META), Err: err}
+	}
+
+	return entry, nil
+}
+
+// Lists every trash entry, oldest first
+func listTrash() ([]TrashEntry, error) {
+
+	dirs, err := os.ReadDir(ATHINA_TRASH)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TrashEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		entry, err := loadTrashEntry(dir.Name())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// Moves the paths of a trash entry back to where they came from.
+// Whatever exists at those paths now is moved into a new trash entry first, so that restoring never loses anything either
+func restoreTrashEntry(id string) error {
+
+	entry, err := loadTrashEntry(id)
+	if err != nil {
+		return err
+	}
+
+	err = touchPaths(entry.Paths)
+	if err != nil {
+		return err
+	}
+
+	err = unpackAthinaObjectPaths(entry.Paths)
+	if err != nil {
+		return err
+	}
+
+	var existing []string
+	for _, path := range entry.Paths {
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+
+	if len(existing) > 0 {
+		_, err := moveToTrash("replaced by restoring "+entry.ID, existing)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range entry.Paths {
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = os.Rename(filepath.Join(entry.dir(), TRASH_ENTRY_FILES, path), path)
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Debug("restored from trash", "id", entry.ID, "paths", entry.Paths)
+
+	// The restored paths may include a whole pack
+	packIndex = nil
+
+	return os.RemoveAll(entry.dir())
+}
+
+// Permanently deletes the given trash entries, or every entry if no ids are given
+func emptyTrash(ids []string) error {
+
+	if len(ids) == 0 {
+		entries, err := listTrash()
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+	}
+
+	for _, id := range ids {
+		entry, err := loadTrashEntry(id)
+		if err != nil {
+			return err
+		}
+
+		err = os.RemoveAll(entry.dir())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const TRASH_USAGE = "athina trash [list | restore [id] | empty [--yes] [id(s)]]"
+
+func handleTrashCommand(args []string) error {
+
+	if len(args) == 0 {
+		return &UsageError{Usage: TRASH_USAGE}
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		entries, err := listTrash()
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s %s %s (%s)\n",
+				colorize(COLOR_YELLOW, entry.ID),
+				entry.Time.Local().Format("2006-01-02 15:04"),
+				entry.Reason,
+				strings.Join(entry.Paths, ", "))
+		}
+
+	case args[0] == "restore" && len(args) == 2:
+		err := restoreTrashEntry(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println("Trash entry \"" + args[1] + "\" has been restored")
+
+	case args[0] == "empty":
+		yes, ids := extractFlag(args[1:], "--yes", "-y")
+
+		// Nothing can bring back what the trash held, so emptying all of it has to be confirmed
+		if len(ids) == 0 && !yes && !confirm("This permanently deletes everything in the trash. Are you sure?") {
+			fmt.Println("Emptying the trash has been cancelled")
+			return nil
+		}
+
+		err := emptyTrash(ids)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Trash has been emptied")
+
+	default:
+		return &UsageError{Usage: TRASH_USAGE}
+	}
+
+	return nil
+}
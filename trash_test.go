@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoadTrashEntryRejectsPaths(t *testing.T) {
+
+	for _, id := range []string{"..", "../..", "../x", "20260101-120000.000000/..", "/tmp", "x", "20260101-120000.000000-", ""} {
+		_, err := loadTrashEntry(id)
+		if !errors.Is(err, ErrTrashEntryNotFound) {
+			t.Errorf("loadTrashEntry(%q) = %v, want %v", id, err, ErrTrashEntryNotFound)
+		}
+	}
+
+	for _, id := range []string{"20260101-120000.000000", "20260101-120000.000000-2"} {
+		if !validTrashID.MatchString(id) {
+			t.Errorf("generated id %q is rejected", id)
+		}
+	}
+}
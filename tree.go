@@ -0,0 +1,554 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every file has a history of its own, so a tree records which version of every file belonged together.
+// Whenever a recorded command (see recordedCommands) or an undo changes any object, a tree with the latest hash of every tracked, not deleted file is appended to
+// the trees.jsonl journal of the current branch. Checking out a tree returns the whole directory to that state at once.
+// Commands that rewrite history keep the trees pointing at versions that still exist: mv renames the file in them,
+// and versions that squash, prune or gc drop are replaced by the version that took their place
+type Tree struct {
+	Hash  string            `json:"hash"`
+	Time  time.Time         `json:"time"`
+	Files map[string]string `json:"files"`
+}
+
+const ATHINA_TREES = "trees.jsonl"
+
+const CHECKOUT_USAGE = "athina checkout [snapshot] [--dry-run] [--force]"
+const SNAPSHOTS_USAGE = "athina snapshots [depth]"
+
+const DEFAULT_SNAPSHOTS_DEPTH int = 10
+
+var errSnapshotNotFound = errors.New("no such snapshot")
+
+func treesPath() string {
+	return branchPath(currentBranch) + ATHINA_TREES
+}
+
+func (t Tree) getHash() string {
+
+	filenames := t.filenames()
+
+	var lines []string
+	for _, filename := range filenames {
+		lines = append(lines, t.Files[filename]+" "+filename)
+	}
+
+	return sha1Hash(strings.Join(lines, "\n") + t.Time.UTC().Format(time.RFC3339Nano))
+}
+
+func (t Tree) filenames() []string {
+
+	var filenames []string
+	for filename := range t.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	return filenames
+}
+
+func (t Tree) sameFiles(other Tree) bool {
+
+	if len(t.Files) != len(other.Files) {
+		return false
+	}
+
+	for filename, hash := range t.Files {
+		if other.Files[filename] != hash {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Loads the trees of the current branch, oldest first
+func loadTrees() ([]Tree, error) {
+
+	file, err := os.Open(treesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var trees []Tree
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var tree Tree
+		err := json.Unmarshal(scanner.Bytes(), &tree)
+		if err != nil {
+			return nil, &CorruptObjectError{Filename: treesPath(), Err: err}
+		}
+		trees = append(trees, tree)
+	}
+
+	return trees, scanner.Err()
+}
+
+// Captures the latest hash of every tracked file that is not deleted
+func currentTree() (Tree, error) {
+
+	filenames, err := AthinaListFiles()
+	if err != nil {
+		return Tree{}, err
+	}
+
+	tree := Tree{Time: time.Now(), Files: map[string]string{}}
+	for _, filename := range filenames {
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return Tree{}, err
+		}
+
+		if !athinafile.isDeleted() {
+			tree.Files[filename] = athinafile.getLatestHash()
+		}
+	}
+
+	return tree, nil
+}
+
+// Appends the current state to the trees of the current branch, unless it is the same as the last tree
+func recordTree() (Tree, bool, error) {
+
+	tree, err := currentTree()
+	if err != nil {
+		return tree, false, err
+	}
+
+	trees, err := loadTrees()
+	if err != nil {
+		return tree, false, err
+	}
+
+	if len(trees) > 0 && trees[len(trees)-1].sameFiles(tree) {
+		return trees[len(trees)-1], false, nil
+	}
+
+	tree.Hash = tree.getHash()
+
+	line, err := json.Marshal(tree)
+	if err != nil {
+		return tree, false, err
+	}
+
+	file, err := os.OpenFile(treesPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return tree, false, err
+	}
+	defer file.Close()
+
+	slog.Debug("recording tree", "hash", tree.Hash, "files", len(tree.Files))
+
+	_, err = file.Write(append(line, '\n'))
+	return tree, true, err
+}
+
+// Applies fn to every tree of the current branch, and rewrites trees.jsonl when fn changed any of them.
+// The journal is replaced in one go, so that it is never left half written
+func rewriteTrees(fn func(tree *Tree) bool) error {
+
+	trees, err := loadTrees()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for i := range trees {
+		if fn(&trees[i]) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	var content []byte
+	for _, tree := range trees {
+		line, err := json.Marshal(tree)
+		if err != nil {
+			return err
+		}
+		content = append(append(content, line...), '\n')
+	}
+
+	err = touchPaths([]string{treesPath()})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(treesPath(), content)
+}
+
+// Points the trees at the versions that replaced the ones they named, when history has been rewritten by squash, prune or gc.
+// replaced maps the hashes of versions that are gone to the hashes of the versions that now stand in for them
+func retargetTrees(filename string, replaced map[string]string) error {
+
+	if len(replaced) == 0 {
+		return nil
+	}
+
+	return rewriteTrees(func(tree *Tree) bool {
+		hash, ok := tree.Files[filename]
+		if !ok {
+			return false
+		}
+
+		if newHash, ok := replaced[hash]; ok && newHash != hash {
+			tree.Files[filename] = newHash
+			return true
+		}
+
+		return false
+	})
+}
+
+// Moves a file to its new name in every tree, when it is renamed
+func renameTreeFile(oldFilename string, newFilename string) error {
+
+	return rewriteTrees(func(tree *Tree) bool {
+		hash, ok := tree.Files[oldFilename]
+		if !ok {
+			return false
+		}
+
+		delete(tree.Files, oldFilename)
+		tree.Files[newFilename] = hash
+		return true
+	})
+}
+
+// Drops a file from every tree, when its history is dropped
+func forgetTreeFile(filename string) error {
+
+	return rewriteTrees(func(tree *Tree) bool {
+		if _, ok := tree.Files[filename]; !ok {
+			return false
+		}
+
+		delete(tree.Files, filename)
+		return true
+	})
+}
+
+// Returns the tree that a snapshot refers to. A snapshot is a full tree hash, a unique prefix of one, "latest" optionally followed by ~n,
+// a repository-wide tag, or a point in time such as "yesterday" or "2026-10-01 17:00", which selects the last tree recorded at or before it
+func resolveSnapshot(snapshot string) (Tree, error) {
+
+	trees, err := loadTrees()
+	if err != nil {
+		return Tree{}, err
+	}
+
+	base, offset := snapshot, 0
+	if i := strings.LastIndex(snapshot, "~"); i >= 0 && snapshot[:i] == REVISION_LATEST {
+		base = snapshot[:i]
+		offset, err = strconv.Atoi(snapshot[i+1:])
+		if snapshot[i+1:] == "" {
+			offset, err = 1, nil
+		}
+		if err != nil || offset < 0 {
+			return Tree{}, &UsageError{Usage: fmt.Sprintf("invalid snapshot %q, the offset after '~' must be a positive number", snapshot)}
+		}
+	}
+
+	if base == REVISION_LATEST {
+		if len(trees)-1-offset < 0 {
+			return Tree{}, &FileError{Filename: snapshot, Err: errSnapshotNotFound}
+		}
+		return trees[len(trees)-1-offset], nil
+	}
+
+	var candidates []Tree
+	for _, tree := range trees {
+		if tree.Hash == snapshot {
+			return tree, nil
+		}
+		if strings.HasPrefix(tree.Hash, snapshot) {
+			candidates = append(candidates, tree)
+		}
+	}
+
+	if validateTagName(snapshot) == nil {
+		tag, err := loadTag(snapshot)
+		if err == nil && !tag.Repository {
+			return Tree{}, &FileError{Filename: snapshot, Err: fmt.Errorf("%w, the tag names a single file and not every file", errSnapshotNotFound)}
+		}
+		if err == nil {
+			return Tree{Hash: tag.Name, Time: tag.Time, Files: tag.Files}, nil
+		}
+		if !errors.Is(err, ErrTagNotFound) {
+			return Tree{}, err
+		}
+	}
+
+	switch len(candidates) {
+	case 1:
+		return candidates[0], nil
+	case 0:
+	default:
+		var hashes []string
+		for _, tree := range candidates {
+			hashes = append(hashes, tree.Hash)
+		}
+		return Tree{}, &AmbiguousRevisionError{Filename: "snapshot", Revision: snapshot, Candidates: hashes}
+	}
+
+	t, err := parseTimeSpec(snapshot, time.Now())
+	if err != nil {
+		return Tree{}, &FileError{Filename: snapshot, Err: errSnapshotNotFound}
+	}
+
+	found := -1
+	for i, tree := range trees {
+		if tree.Time.After(t) {
+			break
+		}
+		found = i
+	}
+
+	if found < 0 {
+		return Tree{}, &FileError{Filename: snapshot, Err: fmt.Errorf("%w at or before %s", errSnapshotNotFound, t.Format("2006-01-02 15:04:05"))}
+	}
+
+	return trees[found], nil
+}
+
+// Reconstructs the content of every file in the tree
+func treeContents(tree Tree) (map[string]string, error) {
+
+	contents := map[string]string{}
+	for _, filename := range tree.filenames() {
+		if !athinaObjectExists(filename) {
+			return nil, &FileError{Filename: filename, Err: fmt.Errorf("%w, its history is gone so the snapshot can not be restored", ErrNotTracked)}
+		}
+
+		athinafile, err := loadAthinaFileObject(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := resolveRevision(athinafile, tree.Files[filename])
+		if err != nil {
+			return nil, err
+		}
+
+		contents[filename], err = emulateDeltaDiffsUntilIndex(athinafile, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return contents, nil
+}
+
+// What checking out a tree does to a single file
+type checkoutAction struct {
+	filename string
+	change   AthinaFileChangeAction
+	content  string
+}
+
+// Works out which files a checkout of the tree has to revert, restore or delete.
+// Working files with unrecorded changes are a conflict unless force is set
+func planCheckout(tree Tree, force bool) ([]checkoutAction, error) {
+
+	current, err := branchContents()
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := treeContents(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []checkoutAction
+	for _, filename := range tree.filenames() {
+		content := contents[filename]
+		recorded, exists := current[filename]
+		switch {
+		case !exists:
+			actions = append(actions, checkoutAction{filename: filename, change: AthinaFileChangeActionRestore, content: content})
+		case recorded != content:
+			actions = append(actions, checkoutAction{filename: filename, change: AthinaFileChangeActionRevert, content: content})
+		}
+	}
+
+	// Files that were added since the snapshot was taken
+	for filename := range current {
+		if _, ok := tree.Files[filename]; !ok {
+			actions = append(actions, checkoutAction{filename: filename, change: AthinaFileChangeActionDelete})
+		}
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].filename < actions[j].filename
+	})
+
+	if force {
+		return actions, nil
+	}
+
+	for _, action := range actions {
+		working, err := readOptionalFile(action.filename)
+		if err != nil {
+			return nil, err
+		}
+
+		recorded, exists := current[action.filename]
+		if working != nil && (!exists || *working != recorded) {
+			return nil, &FileError{Filename: action.filename, Err: fmt.Errorf("%w, record or discard it before checking out, or use --force", ErrDirtyWorkingFile)}
+		}
+	}
+
+	return actions, nil
+}
+
+// Returns every file to its version in the tree. Every change is recorded, as a revert, a restore or a deletion
+func checkoutTree(tree Tree, actions []checkoutAction) error {
+
+	for _, action := range actions {
+		slog.Debug("checking out file", "file", action.filename, "change", action.change, "snapshot", tree.Hash)
+
+		err := touchFile(action.filename)
+		if err != nil {
+			return err
+		}
+
+		if action.change == AthinaFileChangeActionDelete {
+			err = writeOptionalFile(action.filename, nil)
+			if err != nil {
+				return err
+			}
+
+			err = AthinaDeleteFile(action.filename)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		athinafile, err := loadAthinaFileObject(action.filename)
+		if err != nil {
+			return err
+		}
+
+		delta, err := diffAthinaFileObjectAndString(athinafile, action.content)
+		if err != nil {
+			return err
+		}
+
+		filediff := newFilediff(newFileDiffOptions{change: action.change, delta: delta, message: "Checked out " + shortenHash(tree.Hash)})
+		err = AthinaModifyFile(athinafile, []Filediff{filediff})
+		if err != nil {
+			return err
+		}
+
+		err = writeOptionalFile(action.filename, &action.content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func handleCheckoutCommand(args []string) error {
+
+	dryRun, args := extractFlag(args, "--dry-run", "-n")
+	force, args := extractFlag(args, "--force", "-f")
+
+	if len(args) != 1 {
+		return &UsageError{Usage: CHECKOUT_USAGE}
+	}
+
+	tree, err := resolveSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	actions, err := planCheckout(tree, force)
+	if err != nil {
+		return err
+	}
+
+	if len(actions) == 0 {
+		fmt.Println("Already at snapshot " + colorize(COLOR_YELLOW, shortenHash(tree.Hash)))
+		return nil
+	}
+
+	verbs := map[AthinaFileChangeAction][2]string{
+		AthinaFileChangeActionRevert:  {"revert", "Reverted"},
+		AthinaFileChangeActionRestore: {"restore", "Restored"},
+		AthinaFileChangeActionDelete:  {"delete", "Deleted"},
+	}
+
+	if dryRun {
+		for _, action := range actions {
+			fmt.Printf("Would %s %s\n", verbs[action.change][0], action.filename)
+		}
+		return nil
+	}
+
+	err = checkoutTree(tree, actions)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range actions {
+		fmt.Printf("%s %s\n", verbs[action.change][1], action.filename)
+	}
+	fmt.Println("Checked out snapshot " + colorize(COLOR_YELLOW, shortenHash(tree.Hash)))
+
+	return nil
+}
+
+func handleSnapshotsCommand(args []string) error {
+
+	depth := DEFAULT_SNAPSHOTS_DEPTH
+	if len(args) == 1 {
+		var err error
+		depth, err = strconv.Atoi(args[0])
+		if err != nil {
+			return &UsageError{Usage: SNAPSHOTS_USAGE + " (depth must be an integer, but got: " + args[0] + ")"}
+		}
+	} else if len(args) > 1 {
+		return &UsageError{Usage: SNAPSHOTS_USAGE}
+	}
+
+	trees, err := loadTrees()
+	if err != nil {
+		return err
+	}
+
+	for i := len(trees) - 1; i >= 0 && depth > 0; i-- {
+		tree := trees[i]
+		fmt.Printf("%s %s %d files\n", colorize(COLOR_YELLOW, shortenHash(tree.Hash)), tree.Time.Local().Format("2006-01-02 15:04:05"), len(tree.Files))
+		depth--
+	}
+
+	return nil
+}
@@ -1,20 +1,34 @@
 package main
 
+import "strings"
+
 type AthinaFileChangeAction string
 
 const (
-	AthinaFileChangeActionAdd    AthinaFileChangeAction = "File add"
-	AthinaFileChangeActionDelete AthinaFileChangeAction = "File delete"
-	AthinaFileChangeActionModify AthinaFileChangeAction = "File modify"
-	AthinaFileChangeActionRevert AthinaFileChangeAction = "File revert"
-	AthinaFileChangeActionNone   AthinaFileChangeAction = "none"
-	AthinaFileChangeActionError  AthinaFileChangeAction = "error"
+	AthinaFileChangeActionAdd     AthinaFileChangeAction = "File add"
+	AthinaFileChangeActionDelete  AthinaFileChangeAction = "File delete"
+	AthinaFileChangeActionModify  AthinaFileChangeAction = "File modify"
+	AthinaFileChangeActionRevert  AthinaFileChangeAction = "File revert"
+	AthinaFileChangeActionRestore AthinaFileChangeAction = "File restore"
+	AthinaFileChangeActionSquash  AthinaFileChangeAction = "File squash"
+	AthinaFileChangeActionRename  AthinaFileChangeAction = "File rename"
+	AthinaFileChangeActionCopy    AthinaFileChangeAction = "File copy"
+	AthinaFileChangeActionMerge   AthinaFileChangeAction = "File merge"
+	AthinaFileChangeActionPick    AthinaFileChangeAction = "File pick"
+	AthinaFileChangeActionNone    AthinaFileChangeAction = "none"
+	AthinaFileChangeActionError   AthinaFileChangeAction = "error"
 )
 
+// Returns the kind of change without the "File " prefix, e.g. "modify"
+func (a AthinaFileChangeAction) short() string {
+	return strings.TrimPrefix(string(a), "File ")
+}
+
 type AthinaFileChange struct {
 	action   AthinaFileChangeAction
 	file     AthinaFile
 	filename string
+	from     string
 	diffs    []Filediff
 	err      error
 	delta    string